@@ -0,0 +1,161 @@
+package event
+
+import (
+	"context"
+	"errors"
+)
+
+// There are some overflow policies for the async publish queue
+const (
+	// OverflowBlock wait until the queue has free space
+	OverflowBlock = iota
+	// OverflowDrop drop the event, the ErrorHandler will receive ErrQueueFull
+	OverflowDrop
+	// OverflowError return ErrQueueFull to the caller
+	OverflowError
+	// OverflowDropOldest drop the oldest event in the queue, then add the new one
+	OverflowDropOldest
+)
+
+var (
+	// ErrQueueFull the async publish queue is full
+	ErrQueueFull = errors.New("event: the async publish queue is full")
+	// ErrManagerClosed the manager has been closed
+	ErrManagerClosed = errors.New("event: the manager has been closed")
+)
+
+// startWorkers start the async consumers. only run once.
+// the workers are not started if the manager is closed.
+func (em *Manager) startWorkers() {
+	em.queueMu.Lock()
+	defer em.queueMu.Unlock()
+	if em.closed {
+		return
+	}
+
+	em.queue = make(chan IEvent, em.ChannelSize)
+
+	em.workers.Add(em.ConsumerNum)
+	for i := 0; i < em.ConsumerNum; i++ {
+		go em.consume()
+	}
+}
+
+// consume events from the async queue, until the queue is closed.
+func (em *Manager) consume() {
+	defer em.workers.Done()
+
+	for e := range em.queue {
+		if err := em.publish(e); err != nil {
+			em.handleError(e, err)
+		}
+	}
+}
+
+// handleError report async error to the ErrorHandler
+func (em *Manager) handleError(e IEvent, err error) {
+	if em.ErrorHandler != nil {
+		em.ErrorHandler(e, err)
+	}
+}
+
+// AsyncPublish async publish event by the worker pool.
+//
+// When the queue is full, the behavior is decided by Options.Overflow.
+// Listener errors will be reported to the Options.ErrorHandler.
+func (em *Manager) AsyncPublish(e IEvent) error {
+	em.startOnce.Do(em.startWorkers)
+	// stamp on enqueue, the time is the publish time, not the consume time.
+	em.stampEnvelope(context.Background(), e)
+
+	// the lock is not held on sending, the Close will wait the senders done.
+	em.queueMu.RLock()
+	if em.closed {
+		em.queueMu.RUnlock()
+		return ErrManagerClosed
+	}
+	em.senders.Add(1)
+	em.queueMu.RUnlock()
+	defer em.senders.Done()
+
+	if em.Overflow == OverflowBlock {
+		select {
+		case em.queue <- e:
+			return nil
+		case <-em.closing:
+			// the manager is closing, stop waiting for the free space.
+			return ErrManagerClosed
+		}
+	}
+
+	for {
+		select {
+		case em.queue <- e:
+			return nil
+		default:
+		}
+
+		if em.Overflow != OverflowDropOldest {
+			break
+		}
+
+		// drop the oldest, then retry add.
+		select {
+		case old := <-em.queue:
+			em.stats.addAsyncDropped(1)
+			em.handleError(old, ErrQueueFull)
+			continue
+		default:
+		}
+
+		// nothing can be dropped, eg: the queue is unbuffered.
+		break
+	}
+
+	em.stats.addAsyncDropped(1)
+	if em.Overflow == OverflowError {
+		return ErrQueueFull
+	}
+
+	// OverflowDrop
+	em.handleError(e, ErrQueueFull)
+	return nil
+}
+
+// QueueLen get the number of events waiting in the async queue
+func (em *Manager) QueueLen() int {
+	em.queueMu.RLock()
+	defer em.queueMu.RUnlock()
+	return len(em.queue)
+}
+
+// Close the manager. it will stop accept async events, and wait
+// all queued events to be handled. the pending delayed events are discarded.
+// the listeners implements the Flusher will be flushed.
+//
+// The AsyncPublish calls blocked on the full queue will return ErrManagerClosed.
+func (em *Manager) Close() error {
+	em.queueMu.Lock()
+	if em.closed {
+		em.queueMu.Unlock()
+		return ErrManagerClosed
+	}
+
+	em.closed = true
+	close(em.closing)
+	em.queueMu.Unlock()
+
+	// wait the in-flight senders, then the queue can be closed.
+	em.senders.Wait()
+	// the queue is nil if the workers are never started
+	if em.queue != nil {
+		close(em.queue)
+	}
+
+	// stop the delayed events
+	em.scheduler().stop()
+
+	// wait all queued events handled, then flush the pending events of listeners.
+	em.workers.Wait()
+	return em.flushListeners()
+}
@@ -0,0 +1,123 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// chanListener send the events to a channel
+type chanListener struct {
+	ch     chan IEvent
+	policy int
+	stats  *Stats
+
+	// lock for close the channel, the senders hold the read lock.
+	mu     sync.RWMutex
+	done   chan struct{}
+	closed bool
+}
+
+// Handle event. implements the IListener interface
+func (l *chanListener) Handle(e IEvent) error {
+	return l.HandleContext(context.Background(), e)
+}
+
+// HandleContext send the event to the channel by the overflow policy.
+// implements the IContextListener interface
+func (l *chanListener) HandleContext(ctx context.Context, e IEvent) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return nil
+	}
+
+	switch l.policy {
+	case OverflowBlock:
+		select {
+		case l.ch <- e:
+		case <-l.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	case OverflowDropOldest:
+		for {
+			select {
+			case l.ch <- e:
+				return nil
+			default:
+			}
+
+			// drop the oldest, then retry send.
+			select {
+			case <-l.ch:
+				l.stats.addChanDropped(1)
+			default:
+				// nothing can be dropped, eg: the channel is unbuffered.
+				l.stats.addChanDropped(1)
+				return nil
+			}
+		}
+	}
+
+	// OverflowDrop, OverflowError: drop the newest
+	select {
+	case l.ch <- e:
+		return nil
+	default:
+	}
+
+	l.stats.addChanDropped(1)
+	if l.policy == OverflowError {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// close the channel. the blocked senders will be released.
+func (l *chanListener) close() {
+	close(l.done)
+
+	l.mu.Lock()
+	l.closed = true
+	close(l.ch)
+	l.mu.Unlock()
+}
+
+// SubscribeChan subscribe the events to a channel. the name can be an event
+// name or pattern, same as Listen.
+//
+// The policy decide the behavior on the channel is full:
+// 	OverflowBlock      wait until the channel has free space, or the ctx is done
+// 	OverflowDrop       drop the newest event
+// 	OverflowDropOldest drop the oldest event in the channel
+// 	OverflowError      drop the newest event, the publish will return ErrQueueFull
+//
+// Call the cancel func to remove the listener and close the channel.
+//
+// Usage:
+// 	ch, cancel := em.SubscribeChan("order.*", 100, event.OverflowDropOldest)
+// 	defer cancel()
+// 	for e := range ch { ... }
+func (em *Manager) SubscribeChan(name string, bufferSize, policy int) (<-chan IEvent, func()) {
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
+
+	l := &chanListener{
+		ch:     make(chan IEvent, bufferSize),
+		policy: policy,
+		stats:  em.stats,
+		done:   make(chan struct{}),
+	}
+
+	sub := em.Listen(name, l)
+
+	var once sync.Once
+	return l.ch, func() {
+		once.Do(func() {
+			sub.Unsubscribe()
+			l.close()
+		})
+	}
+}
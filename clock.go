@@ -0,0 +1,163 @@
+package event
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source used by the manager. replace it for testing.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is the handle of Clock.AfterFunc
+type Timer interface {
+	// Stop the timer. return false if the timer has been fired or stopped.
+	Stop() bool
+}
+
+// RealClock the clock use the std time package
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+// FakeClock is a manual clock for testing. the time only moves by Advance.
+type FakeClock struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  time.Time
+	// pending waiters, created by After and AfterFunc
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+	fn       func()
+}
+
+// Stop the waiter. implements the Timer interface
+func (w *fakeWaiter) Stop() bool {
+	return w.clock.remove(w)
+}
+
+// NewFakeClock create a fake clock at the time
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now get the current fake time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After the channel will receive the time after Advance over the d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	w := &fakeWaiter{ch: make(chan time.Time, 1)}
+	c.add(w, d)
+	return w.ch
+}
+
+// AfterFunc the fn will be called in the Advance goroutine, after Advance over the d
+func (c *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	w := &fakeWaiter{fn: fn}
+	c.add(w, d)
+	return w
+}
+
+// Advance move the time forward, and fire all expired waiters in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		if len(c.waiters) == 0 || c.waiters[0].deadline.After(c.now) {
+			c.mu.Unlock()
+			return
+		}
+
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		now := c.now
+		c.cond.Broadcast()
+		c.mu.Unlock()
+
+		// call out of the lock, the fn can add new waiters.
+		if w.fn != nil {
+			w.fn()
+		} else {
+			w.ch <- now
+		}
+	}
+}
+
+// Waiters get the number of pending waiters
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil wait until the number of pending waiters is at least n
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *FakeClock) add(w *fakeWaiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.clock = c
+	w.deadline = c.now.Add(d)
+
+	// keep sorted by deadline, and the add order for the same deadline
+	pos := sort.Search(len(c.waiters), func(i int) bool {
+		return c.waiters[i].deadline.After(w.deadline)
+	})
+
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[pos+1:], c.waiters[pos:])
+	c.waiters[pos] = w
+	c.cond.Broadcast()
+}
+
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, cw := range c.waiters {
+		if cw == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package event
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudEvents constants
+const (
+	// CloudEventsVersion the supported CloudEvents spec version
+	CloudEventsVersion = "1.0"
+	// CloudEventsContentType the content type of the structured mode
+	CloudEventsContentType = "application/cloudevents+json"
+	// CloudEventsHeaderPrefix the header prefix of the binary mode
+	CloudEventsHeaderPrefix = "Ce-"
+
+	// the extension attribute names of the envelope correlation fields
+	ceCorrelationID = "correlationid"
+	ceCausationID   = "causationid"
+	// the optional context attribute name of the data content type
+	ceContentType = "datacontenttype"
+)
+
+// ErrInvalidCloudEvent the CloudEvent is invalid
+var ErrInvalidCloudEvent = errors.New("event: invalid cloud event")
+
+// the valid CloudEvents attribute name
+var ceAttrReg = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// the attributes mapped to the event name, data and envelope.
+// the other attributes(subject, dataschema, extensions...) are mapped to the Meta.
+var ceMappedAttrs = map[string]bool{
+	"specversion":   true,
+	"id":            true,
+	"source":        true,
+	"type":          true,
+	"time":          true,
+	"data":          true,
+	"data_base64":   true,
+	ceCorrelationID: true,
+	ceCausationID:   true,
+}
+
+// MarshalCloudEvent encode the event to the CloudEvents structured mode JSON.
+//
+// Mapping:
+// 	Name()          -> type
+// 	Data()          -> data. if only has PayloadKey, the payload is the data, []byte payload is data_base64.
+// 	ID()            -> id
+// 	Source()        -> source
+// 	Time()          -> time
+// 	CorrelationID() -> correlationid
+// 	CausationID()   -> causationid
+// 	Meta()          -> other attributes, such as subject, datacontenttype and extensions.
+//
+// The event must implement IEnvelope and has the ID and source.
+func MarshalCloudEvent(e IEvent) ([]byte, error) {
+	attrs, err := cloudEventAttrs(e)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := make(map[string]interface{}, len(attrs)+1)
+	for name, val := range attrs {
+		obj[name] = val
+	}
+
+	payload, isRaw := cloudEventPayload(e.Data())
+	if isRaw {
+		obj["data_base64"] = base64.StdEncoding.EncodeToString(payload.([]byte))
+	} else if payload != nil {
+		obj["data"] = payload
+	}
+
+	return json.Marshal(obj)
+}
+
+// UnmarshalCloudEvent decode the CloudEvents structured mode JSON to a BasicEvent.
+// see MarshalCloudEvent for the mapping.
+func UnmarshalCloudEvent(bs []byte) (*BasicEvent, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &obj); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCloudEvent, err)
+	}
+
+	attrs := make(map[string]interface{}, len(obj))
+	for name, raw := range obj {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+
+		var val interface{}
+		if err := json.Unmarshal(raw, &val); err != nil {
+			return nil, fmt.Errorf("%w: attribute %q: %v", ErrInvalidCloudEvent, name, err)
+		}
+		attrs[name] = val
+	}
+
+	e, err := newEventFromAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw, ok := obj["data_base64"]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%w: data_base64: %v", ErrInvalidCloudEvent, err)
+		}
+
+		bs, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: data_base64: %v", ErrInvalidCloudEvent, err)
+		}
+		e.SetData(M{PayloadKey: bs})
+	} else if raw, ok := obj["data"]; ok {
+		if err := setJSONData(e, raw); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// CloudEventToHTTP encode the event to the CloudEvents binary mode HTTP message.
+// the attributes are in the "Ce-" headers, the data is the body.
+//
+// The Content-Type is the "datacontenttype" in Meta, default is "application/json".
+// a []byte payload is written as is, default Content-Type is "application/octet-stream".
+func CloudEventToHTTP(e IEvent) (http.Header, []byte, error) {
+	attrs, err := cloudEventAttrs(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := make(http.Header, len(attrs)+1)
+	for name, val := range attrs {
+		if name == ceContentType {
+			continue
+		}
+		h.Set(CloudEventsHeaderPrefix+name, percentEncode(attrString(val)))
+	}
+
+	contentType, _ := attrs[ceContentType].(string)
+	payload, isRaw := cloudEventPayload(e.Data())
+
+	var body []byte
+	switch {
+	case isRaw:
+		body = payload.([]byte)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	case payload == nil:
+	default:
+		// a string payload with non JSON content type, such as text/xml
+		if s, ok := payload.(string); ok && contentType != "" && !isJSONContentType(contentType) {
+			body = []byte(s)
+			break
+		}
+
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, nil, err
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return h, body, nil
+}
+
+// CloudEventFromHTTP decode the CloudEvents HTTP message to a BasicEvent.
+// support the binary mode and the structured mode.
+func CloudEventFromHTTP(h http.Header, body []byte) (*BasicEvent, error) {
+	contentType := h.Get("Content-Type")
+	if strings.HasPrefix(contentType, CloudEventsContentType) {
+		return UnmarshalCloudEvent(body)
+	}
+
+	attrs := make(map[string]interface{})
+	for key, vals := range h {
+		if len(vals) == 0 || !strings.HasPrefix(http.CanonicalHeaderKey(key), CloudEventsHeaderPrefix) {
+			continue
+		}
+
+		val, err := percentDecode(vals[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: header %q: %v", ErrInvalidCloudEvent, key, err)
+		}
+		attrs[strings.ToLower(key[len(CloudEventsHeaderPrefix):])] = val
+	}
+
+	if contentType != "" {
+		attrs[ceContentType] = contentType
+	}
+
+	e, err := newEventFromAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(body) == 0:
+	case contentType == "" || isJSONContentType(contentType):
+		if err := setJSONData(e, body); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(contentType, "text/"):
+		e.SetData(M{PayloadKey: string(body)})
+	default:
+		e.SetData(M{PayloadKey: body})
+	}
+	return e, nil
+}
+
+// cloudEventAttrs collect the context attributes of the event, not contains the data.
+func cloudEventAttrs(e IEvent) (map[string]interface{}, error) {
+	env, ok := e.(IEnvelope)
+	if !ok {
+		return nil, fmt.Errorf("%w: the event %q not implement IEnvelope", ErrInvalidCloudEvent, e.Name())
+	}
+
+	if e.Name() == "" || env.ID() == "" || env.Source() == "" {
+		return nil, fmt.Errorf("%w: the type, id and source are required", ErrInvalidCloudEvent)
+	}
+
+	attrs := map[string]interface{}{
+		"specversion": CloudEventsVersion,
+		"type":        e.Name(),
+		"id":          env.ID(),
+		"source":      env.Source(),
+	}
+
+	if t := env.Time(); !t.IsZero() {
+		attrs["time"] = t.Format(time.RFC3339Nano)
+	}
+	if id := env.CorrelationID(); id != "" {
+		attrs[ceCorrelationID] = id
+	}
+	if id := env.CausationID(); id != "" {
+		attrs[ceCausationID] = id
+	}
+
+	for name, val := range env.Meta() {
+		if ceMappedAttrs[name] || !ceAttrReg.MatchString(name) {
+			return nil, fmt.Errorf("%w: the meta key %q is not a valid attribute name", ErrInvalidCloudEvent, name)
+		}
+		attrs[name] = val
+	}
+	return attrs, nil
+}
+
+// newEventFromAttrs create a BasicEvent by the context attributes
+func newEventFromAttrs(attrs map[string]interface{}) (*BasicEvent, error) {
+	if v, _ := attrs["specversion"].(string); v != CloudEventsVersion {
+		return nil, fmt.Errorf("%w: unsupported specversion %q", ErrInvalidCloudEvent, v)
+	}
+
+	str := func(name string) string {
+		s, _ := attrs[name].(string)
+		return s
+	}
+
+	name, id, source := str("type"), str("id"), str("source")
+	if name == "" || id == "" || source == "" {
+		return nil, fmt.Errorf("%w: the type, id and source are required", ErrInvalidCloudEvent)
+	}
+
+	e := NewBasicEvent(name, nil)
+	e.SetID(id).SetSource(source)
+	e.SetCorrelationID(str(ceCorrelationID)).SetCausationID(str(ceCausationID))
+
+	if s := str("time"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: time: %v", ErrInvalidCloudEvent, err)
+		}
+		e.SetTime(t)
+	}
+
+	for attr, val := range attrs {
+		if !ceMappedAttrs[attr] {
+			e.SetMeta(attr, val)
+		}
+	}
+	return e, nil
+}
+
+// cloudEventPayload get the data payload. if the data only has PayloadKey,
+// the payload is the value. isRaw is true on the payload is []byte.
+func cloudEventPayload(data M) (payload interface{}, isRaw bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	if len(data) == 1 {
+		if val, ok := data[PayloadKey]; ok {
+			_, isRaw = val.([]byte)
+			return val, isRaw
+		}
+	}
+	return data, false
+}
+
+// setJSONData decode the JSON data to the event. a JSON object is the event data,
+// other values are set to the PayloadKey.
+func setJSONData(e *BasicEvent, raw []byte) error {
+	var val interface{}
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return fmt.Errorf("%w: data: %v", ErrInvalidCloudEvent, err)
+	}
+
+	switch v := val.(type) {
+	case nil:
+	case map[string]interface{}:
+		e.SetData(v)
+	default:
+		e.SetData(M{PayloadKey: v})
+	}
+	return nil
+}
+
+// isJSONContentType check the content type is JSON
+func isJSONContentType(contentType string) bool {
+	mt := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+// attrString convert the attribute value to the header string
+func attrString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// percentEncode encode the header value by the CloudEvents HTTP binding:
+// space, double-quote, percent and chars outside the printable ASCII are encoded.
+func percentEncode(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7f || c == '"' || c == '%' {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// percentDecode decode the percent encoded header value
+func percentDecode(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			buf.WriteByte(s[i])
+			continue
+		}
+
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid percent encoding %q", s)
+		}
+
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid percent encoding %q", s)
+		}
+		buf.WriteByte(byte(c))
+		i += 2
+	}
+	return buf.String(), nil
+}
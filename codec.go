@@ -0,0 +1,308 @@
+package event
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownEventType the event type is not registered
+var ErrUnknownEventType = errors.New("event: unknown event type")
+
+// EventRecord is the stable wire record of an event. the codecs encode the
+// events by the record, so the unexported BasicEvent fields can be encoded.
+type EventRecord struct {
+	// Type the registered event type name. empty is the BasicEvent.
+	Type string `json:"type,omitempty"`
+	Name string `json:"name"`
+	Data M      `json:"data,omitempty"`
+
+	// the envelope fields
+	ID            string    `json:"id,omitempty"`
+	Time          time.Time `json:"time"`
+	Source        string    `json:"source,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	CausationID   string    `json:"causation_id,omitempty"`
+	Meta          M         `json:"meta,omitempty"`
+
+	// Payload the JSON of the exported fields of the registered custom type
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// recordSetter set the event fields by the record. the BasicEvent implements it,
+// so the custom types embedded the BasicEvent can be decoded.
+type recordSetter interface {
+	setRecord(r *EventRecord)
+}
+
+// setRecord set the event fields by the record
+func (e *BasicEvent) setRecord(r *EventRecord) {
+	e.name = r.Name
+	e.data = r.Data
+	if e.data == nil {
+		e.data = make(M)
+	}
+
+	e.id = r.ID
+	e.time = r.Time
+	e.source = r.Source
+	e.correlationID = r.CorrelationID
+	e.causationID = r.CausationID
+	e.meta = r.Meta
+}
+
+// TypeRegistry the registry of the custom event types. decode a record will
+// create the registered type, so the listeners can assert the concrete type.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	news  map[string]func() IEvent
+	names map[reflect.Type]string
+}
+
+// DefaultTypes the default event type registry, used by the codecs without Types.
+var DefaultTypes = NewTypeRegistry()
+
+// NewTypeRegistry create a event type registry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		news:  make(map[string]func() IEvent),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// RegisterEventType register a custom event type to the DefaultTypes
+func RegisterEventType(typ string, newFn func() IEvent) {
+	DefaultTypes.Register(typ, newFn)
+}
+
+// Register a custom event type. the newFn must return a new pointer of the
+// type, and the type must embed the BasicEvent. the exported fields of the
+// type are encoded as JSON, in the EventRecord.Payload.
+//
+// Usage:
+// 	type OrderEvent struct {
+// 		event.BasicEvent
+// 		OrderID string
+// 	}
+// 	types.Register("order", func() event.IEvent { return &OrderEvent{} })
+func (tr *TypeRegistry) Register(typ string, newFn func() IEvent) {
+	if typ == "" {
+		panic("event: the event type name cannot be empty")
+	}
+	if newFn == nil {
+		panic("event: the event type constructor cannot be nil")
+	}
+
+	sample := newFn()
+	if _, ok := sample.(recordSetter); !ok {
+		panic(fmt.Sprintf("event: the event type %q must embed the BasicEvent", typ))
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if _, ok := tr.news[typ]; ok {
+		panic(fmt.Sprintf("event: the event type %q is already registered", typ))
+	}
+
+	tr.news[typ] = newFn
+	tr.names[reflect.TypeOf(sample)] = typ
+}
+
+// TypeOf get the registered type name of the event. return empty for not registered.
+func (tr *TypeRegistry) TypeOf(e IEvent) string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	return tr.names[reflect.TypeOf(e)]
+}
+
+// Types get all registered type names, sorted.
+func (tr *TypeRegistry) Types() []string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	types := make([]string, 0, len(tr.news))
+	for typ := range tr.news {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ToRecord convert the event to a record
+func (tr *TypeRegistry) ToRecord(e IEvent) (*EventRecord, error) {
+	r := &EventRecord{
+		Type: tr.TypeOf(e),
+		Name: e.Name(),
+		Data: e.Data(),
+	}
+
+	// the fields of the custom type, the BasicEvent fields are not exported.
+	if r.Type != "" {
+		bs, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("event: encode the fields of the event type %q: %w", r.Type, err)
+		}
+		if string(bs) != "{}" {
+			r.Payload = bs
+		}
+	}
+
+	if env, ok := e.(IEnvelope); ok {
+		r.ID = env.ID()
+		r.Time = env.Time()
+		r.Source = env.Source()
+		r.CorrelationID = env.CorrelationID()
+		r.CausationID = env.CausationID()
+		r.Meta = env.Meta()
+	}
+	return r, nil
+}
+
+// FromRecord create the event by the record. the empty type will create a BasicEvent.
+func (tr *TypeRegistry) FromRecord(r *EventRecord) (IEvent, error) {
+	if r.Type == "" {
+		e := &BasicEvent{}
+		e.setRecord(r)
+		return e, nil
+	}
+
+	tr.mu.RLock()
+	newFn, ok := tr.news[r.Type]
+	tr.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, r.Type)
+	}
+
+	e := newFn()
+	if len(r.Payload) > 0 {
+		if err := json.Unmarshal(r.Payload, e); err != nil {
+			return nil, fmt.Errorf("event: decode the fields of the event type %q: %w", r.Type, err)
+		}
+	}
+	e.(recordSetter).setRecord(r)
+	return e, nil
+}
+
+// EventCodec encode and decode the events. the implementations must be safe
+// for concurrent use.
+type EventCodec interface {
+	// Name the codec name, such as: json, gob, msgpack
+	Name() string
+	// Encode the event to bytes
+	Encode(e IEvent) ([]byte, error)
+	// Decode the bytes to an event
+	Decode(bs []byte) (IEvent, error)
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]EventCodec{}
+)
+
+func init() {
+	// the common types in the event data
+	gob.Register(M{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+
+	RegisterCodec(&JSONCodec{})
+	RegisterCodec(&GobCodec{})
+	RegisterCodec(&MsgpackCodec{})
+}
+
+// RegisterCodec register a codec by the name. will replace the exists codec.
+func RegisterCodec(c EventCodec) {
+	if c == nil {
+		panic("event: the codec cannot be nil")
+	}
+
+	codecMu.Lock()
+	codecs[c.Name()] = c
+	codecMu.Unlock()
+}
+
+// GetCodec get a codec by the name. return nil for not registered.
+func GetCodec(name string) EventCodec {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return codecs[name]
+}
+
+// typesOrDefault return the types, or the DefaultTypes on it is nil
+func typesOrDefault(types *TypeRegistry) *TypeRegistry {
+	if types == nil {
+		return DefaultTypes
+	}
+	return types
+}
+
+// JSONCodec encode the events as JSON. the numbers in data will be decoded as float64.
+type JSONCodec struct {
+	// Types the event types for decode, default is the DefaultTypes
+	Types *TypeRegistry
+}
+
+// Name of the codec
+func (c *JSONCodec) Name() string {
+	return "json"
+}
+
+// Encode the event
+func (c *JSONCodec) Encode(e IEvent) ([]byte, error) {
+	r, err := typesOrDefault(c.Types).ToRecord(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// Decode the event
+func (c *JSONCodec) Decode(bs []byte) (IEvent, error) {
+	r := &EventRecord{}
+	if err := json.Unmarshal(bs, r); err != nil {
+		return nil, err
+	}
+	return typesOrDefault(c.Types).FromRecord(r)
+}
+
+// GobCodec encode the events by the encoding/gob. the custom types in data
+// must be registered by the gob.Register.
+type GobCodec struct {
+	// Types the event types for decode, default is the DefaultTypes
+	Types *TypeRegistry
+}
+
+// Name of the codec
+func (c *GobCodec) Name() string {
+	return "gob"
+}
+
+// Encode the event
+func (c *GobCodec) Encode(e IEvent) ([]byte, error) {
+	r, err := typesOrDefault(c.Types).ToRecord(e)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode the event
+func (c *GobCodec) Decode(bs []byte) (IEvent, error) {
+	r := &EventRecord{}
+	if err := gob.NewDecoder(bytes.NewReader(bs)).Decode(r); err != nil {
+		return nil, err
+	}
+	return typesOrDefault(c.Types).FromRecord(r)
+}
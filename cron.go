@@ -0,0 +1,352 @@
+package event
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression
+type CronSchedule interface {
+	// Next get the next run time after the t. return zero time if not found.
+	Next(t time.Time) time.Time
+}
+
+// ParseCron parse the cron expression.
+//
+// Support the standard 5 fields: minute hour day-of-month month day-of-week
+// 	"*/15 * * * *"   every 15 minutes
+// 	"0 9-18 * * 1-5" every hour from 9 to 18 on weekdays
+// 	"0 0 1 JAN,JUL *"
+//
+// And the descriptors:
+// 	@yearly(@annually), @monthly, @weekly, @daily(@midnight), @hourly
+// 	@every <duration>. eg: "@every 1h30m"
+func ParseCron(spec string) (CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[7:]))
+		if err != nil {
+			return nil, fmt.Errorf("event: invalid cron spec '%s': %w", spec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("event: invalid cron spec '%s': the duration must be positive", spec)
+		}
+		return everySchedule(d), nil
+	}
+
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("event: invalid cron spec '%s': expect 5 fields, got %d", spec, len(fields))
+	}
+
+	cs := &cronSpec{
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+	for i, fb := range cronBounds {
+		if cs.fields[i], err = parseCronField(fields[i], fb); err != nil {
+			return nil, fmt.Errorf("event: invalid cron spec '%s': %w", spec, err)
+		}
+	}
+
+	// 7 is also the Sunday
+	if cs.fields[4]&(1<<7) > 0 {
+		cs.fields[4] |= 1
+	}
+	return cs, nil
+}
+
+// everySchedule run at a fixed interval
+type everySchedule time.Duration
+
+// Next implements the CronSchedule
+func (d everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronBound struct {
+	min, max int
+	names    map[string]int
+}
+
+var cronBounds = [5]cronBound{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31},
+	{min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}},
+	{min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}},
+}
+
+// parseCronField parse a field to a bit set. eg: "1,5-10/2,*/15"
+func parseCronField(field string, fb cronBound) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangeStr, step := part, 1
+		if pos := strings.IndexByte(part, '/'); pos >= 0 {
+			rangeStr = part[:pos]
+			if step, err = strconv.Atoi(part[pos+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in '%s'", part)
+			}
+		}
+
+		var start, end int
+		switch {
+		case rangeStr == "*" || rangeStr == "?":
+			start, end = fb.min, fb.max
+		case strings.IndexByte(rangeStr, '-') > 0:
+			pos := strings.IndexByte(rangeStr, '-')
+			if start, err = parseCronValue(rangeStr[:pos], fb); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(rangeStr[pos+1:], fb); err != nil {
+				return 0, err
+			}
+		default:
+			if start, err = parseCronValue(rangeStr, fb); err != nil {
+				return 0, err
+			}
+
+			// "5/10" is from 5 to max
+			end = start
+			if strings.IndexByte(part, '/') >= 0 {
+				end = fb.max
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range in '%s'", part)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+func parseCronValue(s string, fb cronBound) (int, error) {
+	if v, ok := fb.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < fb.min || v > fb.max {
+		return 0, fmt.Errorf("invalid value '%s', must be in %d-%d", s, fb.min, fb.max)
+	}
+	return v, nil
+}
+
+// cronSpec the standard 5 fields cron expression
+type cronSpec struct {
+	// minute, hour, day-of-month, month, day-of-week
+	fields [5]uint64
+	// the day-of-month or day-of-week is "*"
+	domStar, dowStar bool
+}
+
+func (cs *cronSpec) has(i, v int) bool {
+	return cs.fields[i]&(1<<uint(v)) > 0
+}
+
+// dayMatches check the day by day-of-month and day-of-week.
+// if both are restricted, match any of them.
+func (cs *cronSpec) dayMatches(t time.Time) bool {
+	domOk := cs.has(2, t.Day())
+	dowOk := cs.has(4, int(t.Weekday()))
+	if cs.domStar || cs.dowStar {
+		return domOk && dowOk
+	}
+	return domOk || dowOk
+}
+
+// Next implements the CronSchedule
+func (cs *cronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+search:
+	for t.Year() <= yearLimit {
+		for !cs.has(3, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			if t.Month() == time.January {
+				continue search
+			}
+		}
+
+		for !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			if t.Day() == 1 {
+				continue search
+			}
+		}
+
+		for !cs.has(1, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if t.Hour() == 0 {
+				continue search
+			}
+		}
+
+		for !cs.has(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			if t.Minute() == 0 {
+				continue search
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// CronJob is a recurring event publisher
+type CronJob struct {
+	em       *Manager
+	name     string
+	schedule CronSchedule
+	jitter   time.Duration
+
+	mu       sync.Mutex
+	runCount int
+	next     *Schedule
+	stopped  bool
+}
+
+// CronOption cron job option func
+type CronOption func(j *CronJob)
+
+// WithCronJitter delay each run by a random duration in [0, max)
+func WithCronJitter(max time.Duration) CronOption {
+	return func(j *CronJob) {
+		j.jitter = max
+	}
+}
+
+// Cron publish the event by the cron expression. see ParseCron for the spec.
+// Returns an error if the spec never fires, eg: "0 0 30 2 *"
+//
+// The event params:
+// 	"scheduled_at" time.Time the scheduled run time, without the jitter
+// 	"run_count"    int       the run count, start from 1
+//
+// Usage:
+// 	job, err := em.Cron("@hourly", "system.tick.hourly")
+// 	defer job.Stop()
+func (em *Manager) Cron(spec, name string, opts ...CronOption) (*CronJob, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	now := em.Clock.Now()
+	if schedule.Next(now).IsZero() {
+		return nil, fmt.Errorf("event: the cron spec '%s' never fires", spec)
+	}
+
+	j := &CronJob{em: em, name: checkName(name), schedule: schedule}
+	for _, fn := range opts {
+		fn(j)
+	}
+
+	j.mu.Lock()
+	j.scheduleNext(now)
+	j.mu.Unlock()
+	return j, nil
+}
+
+// Name get the event name
+func (j *CronJob) Name() string {
+	return j.name
+}
+
+// RunCount get the run times
+func (j *CronJob) RunCount() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.runCount
+}
+
+// Next get the next scheduled time. return zero time if it is stopped.
+func (j *CronJob) Next() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stopped || j.next == nil {
+		return time.Time{}
+	}
+	return j.next.at
+}
+
+// Stop the cron job
+func (j *CronJob) Stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.stopped = true
+	if j.next != nil {
+		j.next.Cancel()
+		j.next = nil
+	}
+}
+
+// scheduleNext add the next run to the scheduler. must hold the lock.
+func (j *CronJob) scheduleNext(after time.Time) {
+	at := j.schedule.Next(after)
+	if at.IsZero() {
+		j.next = nil
+		return
+	}
+
+	runAt := at
+	if j.jitter > 0 {
+		runAt = at.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+	}
+
+	j.next = &Schedule{at: runAt, name: j.name}
+	j.next.run = func() {
+		j.run(at)
+	}
+	j.em.scheduler().add(j.next)
+}
+
+// run publish the event, and schedule the next run.
+func (j *CronJob) run(scheduledAt time.Time) {
+	j.mu.Lock()
+	if j.stopped {
+		j.mu.Unlock()
+		return
+	}
+
+	j.runCount++
+	params := M{"scheduled_at": scheduledAt, "run_count": j.runCount}
+	j.scheduleNext(scheduledAt)
+	j.mu.Unlock()
+
+	if err, e := j.em.Publish(j.name, params); err != nil {
+		j.em.handleError(e, err)
+	}
+}
@@ -0,0 +1,405 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrListenerNotFound the listener of the dead letter is removed, or it
+// cannot be resolved after restart, see ListenerItem.Name
+var ErrListenerNotFound = errors.New("event: the listener is not found")
+
+// DeadLetter is a failed event, with the failure info.
+type DeadLetter struct {
+	// ID the dead letter ID, it is set on put if empty
+	ID string
+	// Event the failed event
+	Event IEvent
+	// ListenerID the failed listener ID, it is only valid in the Instance
+	ListenerID uint64
+	// ListenerName the stable name of the failed listener, see ListenerItem.Name
+	ListenerName string
+	// Instance the manager instance ID of the ListenerID
+	Instance string
+	// Err the last error of the listener
+	Err error
+	// Attempts the call times of the listener, contains the retries
+	Attempts int
+	// Time the failed time
+	Time time.Time
+}
+
+// DeadLetterSink receive the events that failed after all retries
+type DeadLetterSink interface {
+	Put(dl *DeadLetter) error
+}
+
+// DeadLetterQueue is a sink that can list and remove the dead letters for re-publish
+type DeadLetterQueue interface {
+	DeadLetterSink
+	// Pending get all dead letters in the put order, they are not removed.
+	Pending() ([]*DeadLetter, error)
+	// Remove the dead letters by ID
+	Remove(ids ...string) error
+}
+
+// putDeadLetter put the failed event to the DeadLetter sink
+func (em *Manager) putDeadLetter(e IEvent, li *ListenerItem, err error, attempts int) {
+	if em.DeadLetter == nil {
+		return
+	}
+
+	dl := &DeadLetter{
+		ID:           NewID(),
+		Event:        e,
+		ListenerID:   li.ID,
+		ListenerName: li.Name,
+		Instance:     em.instance,
+		Err:          err,
+		Attempts:     attempts,
+		Time:         em.Clock.Now(),
+	}
+
+	if sErr := em.DeadLetter.Put(dl); sErr != nil {
+		em.handleError(e, sErr)
+	}
+}
+
+// RepublishDeadLetters redeliver each pending dead letter event to the failed
+// listener only, the other listeners are not called again.
+//
+// The delivered letters are removed after all letters are handled, so the
+// letters may be delivered again after a crash. the letters failed again
+// are put back with the new failure info. the letters not delivered on the
+// ctx is done, or the listener cannot be resolved, are kept in the queue.
+func (em *Manager) RepublishDeadLetters(ctx context.Context, q DeadLetterQueue) (ers []error) {
+	dls, err := q.Pending()
+	if err != nil {
+		return []error{err}
+	}
+
+	var done []string
+	for _, dl := range dls {
+		if err = ctx.Err(); err != nil {
+			ers = append(ers, err)
+			break
+		}
+
+		fdl, err := em.redeliver(ctx, dl)
+		if err == nil {
+			done = append(done, dl.ID)
+			continue
+		}
+
+		ers = append(ers, err)
+		if fdl == nil {
+			continue
+		}
+
+		// put the new letter before remove the old one, so it is not lost on a crash.
+		if err = q.Put(fdl); err != nil {
+			ers = append(ers, err)
+		} else {
+			done = append(done, dl.ID)
+		}
+	}
+
+	if len(done) > 0 {
+		if err = q.Remove(done...); err != nil {
+			ers = append(ers, err)
+		}
+	}
+	return
+}
+
+// redeliver the dead letter event to the failed listener. on the listener
+// failed again, return a new dead letter with the new failure info.
+func (em *Manager) redeliver(ctx context.Context, dl *DeadLetter) (*DeadLetter, error) {
+	e := dl.Event
+	li := em.findDeadLetterListener(dl)
+	if li == nil {
+		return nil, &ListenerError{
+			EventName:  e.Name(),
+			ListenerID: dl.ListenerID,
+			Err:        ErrListenerNotFound,
+		}
+	}
+
+	e.Abort(false)
+	err, _, attempts := em.invokeWithRetry(ctx, li, e)
+	if err == nil {
+		return nil, nil
+	}
+
+	fdl := *dl
+	fdl.ID = NewID()
+	fdl.ListenerID = li.ID
+	fdl.Instance = em.instance
+	fdl.Err = err
+	fdl.Attempts += attempts
+	fdl.Time = em.Clock.Now()
+	return &fdl, &ListenerError{EventName: e.Name(), ListenerID: li.ID, Err: err}
+}
+
+// findDeadLetterListener find the failed listener of the dead letter. find by
+// the listener name if it is set, else by the ID if the letter is put by this
+// manager instance. the IDs are not stable after restart.
+func (em *Manager) findDeadLetterListener(dl *DeadLetter) *ListenerItem {
+	for _, li := range em.registry().match(dl.Event.Name(), em.UnifiedPriority) {
+		if dl.ListenerName != "" {
+			if li.Name == dl.ListenerName {
+				return li
+			}
+		} else if dl.Instance == em.instance && li.ID == dl.ListenerID {
+			return li
+		}
+	}
+	return nil
+}
+
+// MemoryDeadLetters an in-memory dead letter queue
+type MemoryDeadLetters struct {
+	mu    sync.Mutex
+	items []*DeadLetter
+}
+
+// NewMemoryDeadLetters create an in-memory dead letter queue
+func NewMemoryDeadLetters() *MemoryDeadLetters {
+	return &MemoryDeadLetters{}
+}
+
+// Put a dead letter. implements the DeadLetterSink
+func (q *MemoryDeadLetters) Put(dl *DeadLetter) error {
+	if dl.ID == "" {
+		dl.ID = NewID()
+	}
+
+	q.mu.Lock()
+	q.items = append(q.items, dl)
+	q.mu.Unlock()
+	return nil
+}
+
+// Len get the number of dead letters
+func (q *MemoryDeadLetters) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// List get a copy of all dead letters
+func (q *MemoryDeadLetters) List() []*DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*DeadLetter(nil), q.items...)
+}
+
+// Pending get all dead letters. implements the DeadLetterQueue
+func (q *MemoryDeadLetters) Pending() ([]*DeadLetter, error) {
+	return q.List(), nil
+}
+
+// Remove the dead letters by ID. implements the DeadLetterQueue
+func (q *MemoryDeadLetters) Remove(ids ...string) error {
+	rm := toIDSet(ids)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := q.items[:0:0]
+	for _, dl := range q.items {
+		if !rm[dl.ID] {
+			items = append(items, dl)
+		}
+	}
+	q.items = items
+	return nil
+}
+
+// Drain take out all dead letters, the queue will be empty.
+func (q *MemoryDeadLetters) Drain() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := q.items
+	q.items = nil
+	return items, nil
+}
+
+// FileDeadLetters a file-backed dead letter queue. each dead letter is
+// stored as a JSON line, so the event data must be able to encode as JSON.
+//
+// The listener IDs are only valid in the manager instance that put the letters,
+// set the ListenerItem.Name to redeliver the letters after restart.
+type FileDeadLetters struct {
+	mu   sync.Mutex
+	path string
+}
+
+// fileDeadLetter the JSON line format of a dead letter
+type fileDeadLetter struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Data         M         `json:"data,omitempty"`
+	ListenerID   uint64    `json:"listener_id"`
+	ListenerName string    `json:"listener_name,omitempty"`
+	Instance     string    `json:"instance,omitempty"`
+	Error        string    `json:"error"`
+	Attempts     int       `json:"attempts"`
+	Time         time.Time `json:"time"`
+}
+
+// NewFileDeadLetters create a file-backed dead letter queue
+func NewFileDeadLetters(path string) *FileDeadLetters {
+	return &FileDeadLetters{path: path}
+}
+
+// Put a dead letter, append to the file. implements the DeadLetterSink
+func (q *FileDeadLetters) Put(dl *DeadLetter) error {
+	if dl.ID == "" {
+		dl.ID = NewID()
+	}
+
+	line := fileDeadLetter{
+		ID:           dl.ID,
+		Name:         dl.Event.Name(),
+		Data:         dl.Event.Data(),
+		ListenerID:   dl.ListenerID,
+		ListenerName: dl.ListenerName,
+		Instance:     dl.Instance,
+		Attempts:     dl.Attempts,
+		Time:         dl.Time,
+	}
+	if dl.Err != nil {
+		line.Error = dl.Err.Error()
+	}
+
+	bs, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(bs, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Pending read all dead letters. implements the DeadLetterQueue
+func (q *FileDeadLetters) Pending() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lines, err := q.read()
+	if err != nil {
+		return nil, err
+	}
+	return toDeadLetters(lines), nil
+}
+
+// Remove the dead letters by ID, the file is rewritten by a temp file.
+// implements the DeadLetterQueue
+func (q *FileDeadLetters) Remove(ids ...string) error {
+	rm := toIDSet(ids)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lines, err := q.read()
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, line := range lines {
+		if rm[line.ID] {
+			continue
+		}
+
+		bs, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, bs...), '\n')
+	}
+	return writeFileAtomic(q.path, buf)
+}
+
+// Drain read all dead letters, and truncate the file.
+func (q *FileDeadLetters) Drain() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lines, err := q.read()
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	return toDeadLetters(lines), os.Truncate(q.path, 0)
+}
+
+// read all lines of the file, must hold the lock
+func (q *FileDeadLetters) read() ([]*fileDeadLetter, error) {
+	f, err := os.Open(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []*fileDeadLetter
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		line := &fileDeadLetter{}
+		if err = json.Unmarshal(s.Bytes(), line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, s.Err()
+}
+
+// toDeadLetters convert the file lines to the dead letters
+func toDeadLetters(lines []*fileDeadLetter) []*DeadLetter {
+	dls := make([]*DeadLetter, len(lines))
+	for i, line := range lines {
+		dls[i] = &DeadLetter{
+			ID:           line.ID,
+			Event:        NewBasicEvent(line.Name, line.Data),
+			ListenerID:   line.ListenerID,
+			ListenerName: line.ListenerName,
+			Instance:     line.Instance,
+			Err:          errors.New(line.Error),
+			Attempts:     line.Attempts,
+			Time:         line.Time,
+		}
+	}
+	return dls
+}
+
+// toIDSet convert the IDs to a set
+func toIDSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
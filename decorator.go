@@ -0,0 +1,260 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Flusher is implemented by the listeners that hold pending events.
+// the manager will flush them on Close, so no trailing event is lost.
+type Flusher interface {
+	// Flush call the listener with the pending event immediately
+	Flush() error
+}
+
+// delayer is the common part of the Debouncer, Throttler and Coalescer
+type delayer struct {
+	listener IListener
+	// Clock the time source. default is RealClock
+	Clock Clock
+	// ErrorHandler receive the listener errors of the delayed calls
+	ErrorHandler func(e IEvent, err error)
+
+	mu      sync.Mutex
+	timer   Timer
+	pending IEvent
+	// gen is increased on the timer reset, the stale timer will be ignored.
+	gen uint64
+}
+
+// startTimer start a new timer, must hold the lock.
+func (d *delayer) startTimer(wait time.Duration, fn func()) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+
+	d.gen++
+	gen := d.gen
+	d.timer = d.Clock.AfterFunc(wait, func() {
+		d.mu.Lock()
+		if gen != d.gen {
+			d.mu.Unlock()
+			return
+		}
+		d.timer = nil
+		d.mu.Unlock()
+
+		fn()
+	})
+}
+
+// takePending take out the pending event and stop the timer
+func (d *delayer) takePending(stopTimer bool) IEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if stopTimer && d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+		d.gen++
+	}
+
+	e := d.pending
+	d.pending = nil
+	return e
+}
+
+// call the listener
+func (d *delayer) call(e IEvent) error {
+	if cl, ok := d.listener.(IContextListener); ok {
+		return cl.HandleContext(context.Background(), e)
+	}
+	return d.listener.Handle(e)
+}
+
+// callDelayed call the listener in the timer, report the error to ErrorHandler
+func (d *delayer) callDelayed(e IEvent) {
+	if err := d.call(e); err != nil && d.ErrorHandler != nil {
+		d.ErrorHandler(e, err)
+	}
+}
+
+// Flush call the listener with the pending event. implements the Flusher
+func (d *delayer) Flush() error {
+	if e := d.takePending(true); e != nil {
+		return d.call(e)
+	}
+	return nil
+}
+
+// Debouncer call the listener with the last event, after no new event in the window.
+type Debouncer struct {
+	delayer
+	window time.Duration
+}
+
+// Debounce wrap the listener, it is called with the last event after the
+// window has passed without new events.
+//
+// Usage:
+// 	em.Listen("config.changed", event.Debounce(reloadListener, time.Second), event.High)
+func Debounce(listener IListener, window time.Duration) *Debouncer {
+	return &Debouncer{delayer: delayer{listener: listener, Clock: RealClock}, window: window}
+}
+
+// Handle event. implements the IListener interface
+func (d *Debouncer) Handle(e IEvent) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = e
+	d.startTimer(d.window, func() {
+		if e := d.takePending(false); e != nil {
+			d.callDelayed(e)
+		}
+	})
+	return nil
+}
+
+// Throttler call the listener at most once in the interval.
+type Throttler struct {
+	delayer
+	interval time.Duration
+	// active is true in an interval
+	active bool
+}
+
+// Throttle wrap the listener, it is called at most once in the interval.
+// the first event is handled immediately, the last event in the interval
+// is handled at the end of the interval.
+func Throttle(listener IListener, interval time.Duration) *Throttler {
+	return &Throttler{delayer: delayer{listener: listener, Clock: RealClock}, interval: interval}
+}
+
+// Handle event. implements the IListener interface
+func (t *Throttler) Handle(e IEvent) error {
+	t.mu.Lock()
+	if t.active {
+		t.pending = e
+		t.mu.Unlock()
+		return nil
+	}
+
+	t.active = true
+	t.startTimer(t.interval, t.tick)
+	t.mu.Unlock()
+
+	// the leading event is handled in the publish
+	return t.call(e)
+}
+
+// tick on the interval end, handle the trailing event and start a new interval.
+func (t *Throttler) tick() {
+	t.mu.Lock()
+	e := t.pending
+	t.pending = nil
+	if e != nil {
+		t.startTimer(t.interval, t.tick)
+	} else {
+		t.active = false
+	}
+	t.mu.Unlock()
+
+	if e != nil {
+		t.callDelayed(e)
+	}
+}
+
+// Flush call the listener with the trailing event, and end the interval.
+// implements the Flusher
+func (t *Throttler) Flush() error {
+	e := t.takePending(true)
+
+	t.mu.Lock()
+	t.active = false
+	t.mu.Unlock()
+
+	if e != nil {
+		return t.call(e)
+	}
+	return nil
+}
+
+// Coalescer merge the events in the window, and call the listener once.
+type Coalescer struct {
+	delayer
+	window  time.Duration
+	mergeFn func(prev, next IEvent) IEvent
+}
+
+// Coalesce wrap the listener, the events in the window are merged by the
+// mergeFn, and the listener is called with the merged event at the window end.
+//
+// Usage:
+// 	Coalesce(listener, time.Second, func(prev, next event.IEvent) event.IEvent {
+// 		next.Set("count", prev.Get("count").(int)+1)
+// 		return next
+// 	})
+func Coalesce(listener IListener, window time.Duration, mergeFn func(prev, next IEvent) IEvent) *Coalescer {
+	if mergeFn == nil {
+		panic("event: the coalesce merge func cannot be nil")
+	}
+	return &Coalescer{
+		delayer: delayer{listener: listener, Clock: RealClock},
+		window:  window,
+		mergeFn: mergeFn,
+	}
+}
+
+// Handle event. implements the IListener interface
+func (c *Coalescer) Handle(e IEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending != nil {
+		c.pending = c.mergeFn(c.pending, e)
+		return nil
+	}
+
+	// the first event, start the window.
+	c.pending = e
+	c.startTimer(c.window, func() {
+		if e := c.takePending(false); e != nil {
+			c.callDelayed(e)
+		}
+	})
+	return nil
+}
+
+// flushListeners flush all listeners implements the Flusher,
+// and the removed ListenTimes listeners that may hold a pending event.
+func (em *Manager) flushListeners() error {
+	var errs []*ListenerError
+	for name, lq := range em.registry().listeners {
+		for _, li := range lq.Items() {
+			f, ok := li.Listener.(Flusher)
+			if !ok {
+				continue
+			}
+
+			if err := f.Flush(); err != nil {
+				errs = append(errs, &ListenerError{EventName: name, ListenerID: li.ID, Err: err})
+			}
+		}
+	}
+
+	em.removedFlushers.Range(func(key, val interface{}) bool {
+		em.removedFlushers.Delete(key)
+		tl := val.(*timesListener)
+		if err := tl.Flush(); err != nil {
+			errs = append(errs, &ListenerError{EventName: tl.name, ListenerID: tl.li.ID, Err: err})
+		}
+		return true
+	})
+
+	if len(errs) > 0 {
+		return &ListenerErrors{Errors: errs}
+	}
+	return nil
+}
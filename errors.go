@@ -0,0 +1,101 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PanicError the error of a recovered listener panic
+type PanicError struct {
+	// EventName the handling event name
+	EventName string
+	// ListenerID the panicked listener ID
+	ListenerID uint64
+	// Value the value of recover()
+	Value interface{}
+	// Stack trace of the panic
+	Stack []byte
+}
+
+// Error string
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("event: listener #%d panic on handle the event '%s': %v", e.ListenerID, e.EventName, e.Value)
+}
+
+// Unwrap get the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// ListenerError the error returned by a listener
+type ListenerError struct {
+	// EventName the handling event name
+	EventName string
+	// ListenerID the failed listener ID
+	ListenerID uint64
+	// Err the listener returned error
+	Err error
+}
+
+// Error string
+func (e *ListenerError) Error() string {
+	return fmt.Sprintf("event: listener #%d handle the event '%s' error: %v", e.ListenerID, e.EventName, e.Err)
+}
+
+// Unwrap get the listener returned error
+func (e *ListenerError) Unwrap() error {
+	return e.Err
+}
+
+// ListenerErrors aggregate the errors of multi listeners. it is returned
+// on the ContinueOnError is enabled.
+//
+// Support errors.Is and errors.As to check each listener error.
+type ListenerErrors struct {
+	Errors []*ListenerError
+}
+
+// Error string
+func (es *ListenerErrors) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("event: %d listener(s) failed:", len(es.Errors)))
+
+	for _, le := range es.Errors {
+		sb.WriteString("\n - ")
+		sb.WriteString(le.Error())
+	}
+	return sb.String()
+}
+
+// Is check any listener error is the target
+func (es *ListenerErrors) Is(target error) bool {
+	for _, le := range es.Errors {
+		if errors.Is(le, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As find the first listener error that matches target
+func (es *ListenerErrors) As(target interface{}) bool {
+	for _, le := range es.Errors {
+		if errors.As(le, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap get all listener errors
+func (es *ListenerErrors) Unwrap() []error {
+	errs := make([]error, len(es.Errors))
+	for i, le := range es.Errors {
+		errs[i] = le
+	}
+	return errs
+}
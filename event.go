@@ -1,5 +1,10 @@
 package event
 
+import (
+	"context"
+	"time"
+)
+
 // IEvent defined the event interface
 type IEvent interface {
 	Name() string
@@ -12,12 +17,47 @@ type IEvent interface {
 	IsAborted() bool
 }
 
+// IEnvelope is the event envelope interface, for correlate and dedupe events.
+// the BasicEvent implements it.
+type IEnvelope interface {
+	// ID unique event ID
+	ID() string
+	// Time the event create time
+	Time() time.Time
+	// Source the source manager name
+	Source() string
+	// CorrelationID the ID of the first event in a chain
+	CorrelationID() string
+	// CausationID the ID of the event that caused this event
+	CausationID() string
+	// Meta the metadata, separate from the business data
+	Meta() M
+}
+
+// envelopeStamper fill the empty envelope fields. the BasicEvent implements it.
+type envelopeStamper interface {
+	stampEnvelope(source string, now time.Time, cause IEvent)
+}
+
+// envelopeResetter clear the stamped ID and time. the BasicEvent implements it.
+type envelopeResetter interface {
+	resetEnvelope()
+}
+
 // BasicEvent define a basic event struct
 type BasicEvent struct {
 	name string
 	data map[string]interface{}
 	// mark is aborted
 	aborted bool
+
+	// envelope fields
+	id            string
+	time          time.Time
+	source        string
+	correlationID string
+	causationID   string
+	meta          M
 }
 
 // SetName set event name
@@ -78,3 +118,136 @@ func (e *BasicEvent) Abort(abort bool) {
 func (e *BasicEvent) IsAborted() bool {
 	return e.aborted
 }
+
+// SetID set the event ID
+func (e *BasicEvent) SetID(id string) *BasicEvent {
+	e.id = id
+	return e
+}
+
+// ID get the event ID
+func (e *BasicEvent) ID() string {
+	return e.id
+}
+
+// SetTime set the event create time
+func (e *BasicEvent) SetTime(t time.Time) *BasicEvent {
+	e.time = t
+	return e
+}
+
+// Time get the event create time
+func (e *BasicEvent) Time() time.Time {
+	return e.time
+}
+
+// SetSource set the event source
+func (e *BasicEvent) SetSource(source string) *BasicEvent {
+	e.source = source
+	return e
+}
+
+// Source get the event source
+func (e *BasicEvent) Source() string {
+	return e.source
+}
+
+// SetCorrelationID set the correlation ID
+func (e *BasicEvent) SetCorrelationID(id string) *BasicEvent {
+	e.correlationID = id
+	return e
+}
+
+// CorrelationID get the correlation ID
+func (e *BasicEvent) CorrelationID() string {
+	return e.correlationID
+}
+
+// SetCausationID set the causation ID
+func (e *BasicEvent) SetCausationID(id string) *BasicEvent {
+	e.causationID = id
+	return e
+}
+
+// CausationID get the causation ID
+func (e *BasicEvent) CausationID() string {
+	return e.causationID
+}
+
+// CausedBy set the causation and correlation ID by the parent event
+func (e *BasicEvent) CausedBy(parent IEvent) *BasicEvent {
+	pe, ok := parent.(IEnvelope)
+	if !ok {
+		return e
+	}
+
+	e.causationID = pe.ID()
+	e.correlationID = pe.CorrelationID()
+	if e.correlationID == "" {
+		e.correlationID = pe.ID()
+	}
+	return e
+}
+
+// SetMeta set a metadata value by key
+func (e *BasicEvent) SetMeta(key string, val interface{}) *BasicEvent {
+	if e.meta == nil {
+		e.meta = make(M)
+	}
+
+	e.meta[key] = val
+	return e
+}
+
+// GetMeta get a metadata value by key
+func (e *BasicEvent) GetMeta(key string) interface{} {
+	return e.meta[key]
+}
+
+// Meta get all metadata
+func (e *BasicEvent) Meta() M {
+	return e.meta
+}
+
+// resetEnvelope clear the ID and time, they are stamped again on the next publish
+func (e *BasicEvent) resetEnvelope() {
+	e.id = ""
+	e.time = time.Time{}
+}
+
+// stampEnvelope fill the empty envelope fields
+func (e *BasicEvent) stampEnvelope(source string, now time.Time, cause IEvent) {
+	if e.id == "" {
+		e.id = newULID(now)
+	}
+	if e.time.IsZero() {
+		e.time = now
+	}
+	if e.source == "" {
+		e.source = source
+	}
+	if cause != nil && e.causationID == "" {
+		e.CausedBy(cause)
+	}
+}
+
+// causeCtxKey the context key of the cause event
+type causeCtxKey struct{}
+
+// WithCause set the cause event to the context. the events published with
+// the context will set the causation and correlation ID by the cause event.
+//
+// Usage:
+// 	em.Listen("order.created", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+// 		_, _ = em.PublishContext(event.WithCause(ctx, e), "stock.reserved", nil)
+// 		return nil
+// 	}))
+func WithCause(ctx context.Context, cause IEvent) context.Context {
+	return context.WithValue(ctx, causeCtxKey{}, cause)
+}
+
+// causeFromContext get the cause event from the context
+func causeFromContext(ctx context.Context) IEvent {
+	e, _ := ctx.Value(causeCtxKey{}).(IEvent)
+	return e
+}
@@ -0,0 +1,339 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// AnyVersion skip the expected version check on append
+const AnyVersion = -1
+
+// VersionConflictError the expected version is not the current stream version
+type VersionConflictError struct {
+	StreamID string
+	Expected int
+	Actual   int
+}
+
+// Error string
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("event: version conflict on stream '%s', expected %d, actual %d", e.StreamID, e.Expected, e.Actual)
+}
+
+// Snapshot a snapshot of an aggregate state
+type Snapshot struct {
+	AggregateID string `json:"aggregate_id"`
+	Version     int    `json:"version"`
+	State       []byte `json:"state"`
+}
+
+// EventStore storage the event streams, for the event sourcing.
+// the implementations must be safe for concurrent use.
+type EventStore interface {
+	// Append events to the stream. the expectedVersion must be the current
+	// stream version, or AnyVersion. else return *VersionConflictError
+	Append(streamID string, expectedVersion int, events []IEvent) error
+	// Load the events of the stream after the version, in order
+	Load(streamID string, afterVersion int) ([]IEvent, error)
+	// Version get the current version of the stream, 0 is not exists.
+	Version(streamID string) (int, error)
+	// SaveSnapshot save the latest snapshot
+	SaveSnapshot(s *Snapshot) error
+	// LoadSnapshot load the latest snapshot, return nil if not exists.
+	LoadSnapshot(streamID string) (*Snapshot, error)
+}
+
+// checkVersion check the expected version
+func checkVersion(streamID string, expected, actual int) error {
+	if expected != AnyVersion && expected != actual {
+		return &VersionConflictError{StreamID: streamID, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// MemoryEventStore an in-memory event store. the events are stored as the
+// EventRecord copies, the changes after append will not change the stored
+// events. the data values are copied shallowly.
+type MemoryEventStore struct {
+	mu        sync.RWMutex
+	types     *TypeRegistry
+	streams   map[string][]*EventRecord
+	snapshots map[string]*Snapshot
+}
+
+// NewMemoryEventStore create an in-memory event store
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		types:     DefaultTypes,
+		streams:   make(map[string][]*EventRecord),
+		snapshots: make(map[string]*Snapshot),
+	}
+}
+
+// Append events to the stream
+func (s *MemoryEventStore) Append(streamID string, expectedVersion int, events []IEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := checkVersion(streamID, expectedVersion, len(s.streams[streamID])); err != nil {
+		return err
+	}
+
+	rs := make([]*EventRecord, len(events))
+	for i, e := range events {
+		r, err := s.types.ToRecord(e)
+		if err != nil {
+			return err
+		}
+		rs[i] = copyRecord(r)
+	}
+
+	s.streams[streamID] = append(s.streams[streamID], rs...)
+	return nil
+}
+
+// Load the events of the stream after the version
+func (s *MemoryEventStore) Load(streamID string, afterVersion int) ([]IEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	es := s.streams[streamID]
+	if afterVersion < 0 {
+		afterVersion = 0
+	}
+	if afterVersion >= len(es) {
+		return nil, nil
+	}
+	var events []IEvent
+	for _, r := range es[afterVersion:] {
+		e, err := s.types.FromRecord(copyRecord(r))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// copyRecord copy the record and the data, meta maps
+func copyRecord(r *EventRecord) *EventRecord {
+	cp := *r
+	cp.Data = copyM(r.Data)
+	cp.Meta = copyM(r.Meta)
+	return &cp
+}
+
+// Version get the current version of the stream
+func (s *MemoryEventStore) Version(streamID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.streams[streamID]), nil
+}
+
+// SaveSnapshot save the latest snapshot
+func (s *MemoryEventStore) SaveSnapshot(snap *Snapshot) error {
+	s.mu.Lock()
+	s.snapshots[snap.AggregateID] = snap
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadSnapshot load the latest snapshot
+func (s *MemoryEventStore) LoadSnapshot(streamID string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshots[streamID], nil
+}
+
+// FileEventStore a file-backed event store. each stream is stored in a file
+// with the journal record format, the snapshot is a JSON file.
+type FileEventStore struct {
+	mu    sync.Mutex
+	dir   string
+	codec EventCodec
+	// cached stream versions
+	versions map[string]int
+}
+
+// NewFileEventStore create a file-backed event store in the dir.
+// the codec encode the events, default is the JSONCodec.
+func NewFileEventStore(dir string, codec EventCodec) (*FileEventStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if codec == nil {
+		codec = &JSONCodec{}
+	}
+	return &FileEventStore{dir: dir, codec: codec, versions: make(map[string]int)}, nil
+}
+
+// streamPath get the file path of the stream
+func (s *FileEventStore) streamPath(streamID, ext string) string {
+	return filepath.Join(s.dir, url.PathEscape(streamID)+ext)
+}
+
+// version get the stream version, an incomplete record at the end will be
+// truncated. a corrupt record in the middle will return ErrJournalCorrupt.
+func (s *FileEventStore) version(streamID string) (int, error) {
+	if v, ok := s.versions[streamID]; ok {
+		return v, nil
+	}
+
+	path := s.streamPath(streamID, ".events")
+	var v int
+	end, err := readSegment(path, -1, func(seq uint64, _ int64, _ []byte) error {
+		v = int(seq)
+		return nil
+	})
+
+	switch {
+	case os.IsNotExist(err):
+	case errors.Is(err, errJournalTorn):
+		if err = os.Truncate(path, end); err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	}
+
+	s.versions[streamID] = v
+	return v, nil
+}
+
+// Append events to the stream
+func (s *FileEventStore) Append(streamID string, expectedVersion int, events []IEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.version(streamID)
+	if err != nil {
+		return err
+	}
+	if err = checkVersion(streamID, expectedVersion, v); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for i, e := range events {
+		bs, err := s.codec.Encode(e)
+		if err != nil {
+			return err
+		}
+		if err = checkRecordSize(bs); err != nil {
+			return err
+		}
+
+		var nano int64
+		if env, ok := e.(IEnvelope); ok {
+			nano = env.Time().UnixNano()
+		}
+		buf = append(buf, encodeRecord(uint64(v+i+1), nano, bs)...)
+	}
+
+	f, err := os.OpenFile(s.streamPath(streamID, ".events"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	// write all events once, an incomplete write will be truncated on next load.
+	if _, err = f.Write(buf); err != nil {
+		_ = f.Close()
+		delete(s.versions, streamID)
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		delete(s.versions, streamID)
+		return err
+	}
+
+	s.versions[streamID] = v + len(events)
+	return f.Close()
+}
+
+// Load the events of the stream after the version
+func (s *FileEventStore) Load(streamID string, afterVersion int) ([]IEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.version(streamID)
+	if err != nil || v <= afterVersion {
+		return nil, err
+	}
+
+	var es []IEvent
+	_, err = readSegment(s.streamPath(streamID, ".events"), -1, func(seq uint64, _ int64, bs []byte) error {
+		if int(seq) <= afterVersion {
+			return nil
+		}
+
+		e, err := s.codec.Decode(bs)
+		if err != nil {
+			return fmt.Errorf("%w: decode the stream '%s' version %d: %v", ErrJournalCorrupt, streamID, seq, err)
+		}
+		es = append(es, e)
+		return nil
+	})
+	return es, err
+}
+
+// Version get the current version of the stream
+func (s *FileEventStore) Version(streamID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version(streamID)
+}
+
+// SaveSnapshot save the latest snapshot. write to a temp file then rename,
+// so the snapshot file is always complete.
+func (s *FileEventStore) SaveSnapshot(snap *Snapshot) error {
+	bs, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.CreateTemp(s.dir, "snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(bs); err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.streamPath(snap.AggregateID, ".snapshot"))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// LoadSnapshot load the latest snapshot
+func (s *FileEventStore) LoadSnapshot(streamID string) (*Snapshot, error) {
+	bs, err := os.ReadFile(s.streamPath(streamID, ".snapshot"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	snap := &Snapshot{}
+	if err = json.Unmarshal(bs, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
@@ -1,6 +1,10 @@
 package event
 
-import "strings"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 // NewBasicEvent new a basic event instance
 func NewBasicEvent(name string, data M) *BasicEvent {
@@ -26,3 +30,39 @@ func checkName(name string) string {
 
 	return name
 }
+
+// writeFileAtomic write the data to a temp file in the same dir, then rename
+// to the path. so the file is always complete on a crash.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// copyM copy the map, nil will return nil
+func copyM(m M) M {
+	if m == nil {
+		return nil
+	}
+
+	cp := make(M, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
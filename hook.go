@@ -0,0 +1,66 @@
+package event
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// PublishHook is called on an event is published, before call the listeners.
+// return an error will stop the publish, the error is returned to the publisher.
+type PublishHook func(ctx context.Context, e IEvent) error
+
+// publishHook a registered hook
+type publishHook struct {
+	id uint64
+	fn PublishHook
+}
+
+// OnPublish add a publish hook, the hooks are called in the add order.
+// the events rejected by the rate limits will not call the hooks.
+// call the returned func to remove the hook.
+func (em *Manager) OnPublish(hook PublishHook) func() {
+	if hook == nil {
+		panic("event: the publish hook cannot be nil")
+	}
+
+	h := &publishHook{id: atomic.AddUint64(&em.lastID, 1), fn: hook}
+	em.update(func(r *registry) {
+		r.hooks = append(r.hooks, h)
+	})
+
+	return func() {
+		em.update(func(r *registry) {
+			for i, rh := range r.hooks {
+				if rh.id == h.id {
+					r.hooks = append(r.hooks[:i:i], r.hooks[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// callHooks call the publish hooks of the registry
+func (em *Manager) callHooks(ctx context.Context, r *registry, e IEvent) error {
+	for _, h := range r.hooks {
+		if err := h.fn(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayCtxKey the context key of the replay mark
+type replayCtxKey struct{}
+
+// withReplay mark the context is replaying events
+func withReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayCtxKey{}, true)
+}
+
+// IsReplay check the event is published by a replay, such as Journal.Replay.
+// the listeners can use it to skip the side effects.
+func IsReplay(ctx context.Context) bool {
+	v, _ := ctx.Value(replayCtxKey{}).(bool)
+	return v
+}
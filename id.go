@@ -0,0 +1,46 @@
+package event
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+// crockford base32 alphabet for the ULID
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID generate a unique event ID. it is a ULID, sortable by the create time.
+func NewID() string {
+	return newULID(time.Now())
+}
+
+// newULID generate a ULID: 48 bits milliseconds timestamp + 80 bits random
+func newULID(t time.Time) string {
+	var b [16]byte
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("event: generate the event ID error: " + err.Error())
+	}
+
+	// encode 128 bits to 26 chars, 5 bits per char. the first char has 3 bits.
+	var out [26]byte
+	var acc uint64
+	var bits uint
+	pos := 25
+	for i := 15; i >= 0; i-- {
+		acc |= uint64(b[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = ulidAlphabet[acc&0x1f]
+			acc >>= 5
+			bits -= 5
+			pos--
+		}
+	}
+	out[0] = ulidAlphabet[acc&0x1f]
+	return string(out[:])
+}
@@ -0,0 +1,555 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// There are some fsync policies for the journal
+const (
+	// JournalSyncNone never call fsync, leave it to the OS
+	JournalSyncNone = iota
+	// JournalSyncAlways call fsync after each record
+	JournalSyncAlways
+	// JournalSyncInterval call fsync at most once per the SyncInterval, it is checked on write.
+	JournalSyncInterval
+)
+
+const (
+	// the segment file extension
+	journalExt = ".journal"
+	// record header: payload length(4) + crc32 of the payload(4)
+	journalHeaderSize = 8
+	// payload prefix: seq(8) + time unix nano(8)
+	journalMetaSize = 16
+	// the max size of a record payload
+	maxJournalRecord = 256 << 20
+	// DefaultSegmentSize the default max size of a journal segment file
+	DefaultSegmentSize = 64 << 20
+)
+
+var (
+	// ErrJournalClosed the journal is closed
+	ErrJournalClosed = errors.New("event: the journal is closed")
+	// ErrJournalCorrupt the journal record is corrupt
+	ErrJournalCorrupt = errors.New("event: the journal is corrupt")
+	// ErrJournalRecordTooLarge the encoded event is larger than the max record size
+	ErrJournalRecordTooLarge = errors.New("event: the journal record is too large")
+
+	// errJournalTorn the last record is incomplete
+	errJournalTorn = errors.New("event: the journal record is incomplete")
+)
+
+// JournalOptions the journal options
+type JournalOptions struct {
+	// SegmentSize the max size of a segment file, a new segment is created on exceeded.
+	// default is DefaultSegmentSize
+	SegmentSize int64
+	// SyncPolicy the fsync policy. default is JournalSyncNone
+	SyncPolicy int
+	// SyncInterval the fsync interval of the JournalSyncInterval
+	SyncInterval time.Duration
+	// Codec encode the events. default is the JSONCodec.
+	// must use the same codec on reopen the journal.
+	Codec EventCodec
+	// Clock the time source for the record time and sync interval. default is RealClock
+	Clock Clock
+}
+
+// JournalOptionFn func
+type JournalOptionFn func(o *JournalOptions)
+
+// WithSegmentSize set the max size of a segment file
+func WithSegmentSize(size int64) JournalOptionFn {
+	return func(o *JournalOptions) {
+		o.SegmentSize = size
+	}
+}
+
+// WithSyncPolicy set the fsync policy, the interval is used by the JournalSyncInterval
+func WithSyncPolicy(policy int, interval time.Duration) JournalOptionFn {
+	return func(o *JournalOptions) {
+		o.SyncPolicy = policy
+		o.SyncInterval = interval
+	}
+}
+
+// WithJournalCodec set the codec of the journal records
+func WithJournalCodec(c EventCodec) JournalOptionFn {
+	return func(o *JournalOptions) {
+		o.Codec = c
+	}
+}
+
+// WithJournalClock set the clock of the journal
+func WithJournalClock(c Clock) JournalOptionFn {
+	return func(o *JournalOptions) {
+		o.Clock = c
+	}
+}
+
+// JournalEntry a recorded event of the journal
+type JournalEntry struct {
+	// Seq the sequence number, start from 1
+	Seq uint64
+	// Time the event time, or the record time if the event has no envelope time.
+	Time time.Time
+	// Event the decoded event
+	Event IEvent
+}
+
+// Journal a durable append-only event journal. the records are stored in
+// segment files, each record has a CRC32 checksum.
+//
+// Record format(big endian):
+// 	length(4) crc32(4) seq(8) time(8) event(length-16)
+//
+// Usage:
+// 	j, err := event.OpenJournal("./data/journal")
+// 	detach := j.Attach(em)
+// 	...
+// 	n, err := j.Replay(ctx, em, from, to, nil)
+type Journal struct {
+	JournalOptions
+	mu  sync.Mutex
+	dir string
+	// current segment file and size
+	file *os.File
+	size int64
+	// the last sequence number
+	lastSeq  uint64
+	lastSync time.Time
+	closed   bool
+}
+
+// OpenJournal open or create a journal in the dir. an incomplete record at the
+// end of the last segment will be truncated, it is left by a crash on write.
+func OpenJournal(dir string, fns ...JournalOptionFn) (*Journal, error) {
+	j := &Journal{dir: dir}
+	for _, fn := range fns {
+		fn(&j.JournalOptions)
+	}
+
+	if j.SegmentSize <= 0 {
+		j.SegmentSize = DefaultSegmentSize
+	}
+	if j.Codec == nil {
+		j.Codec = &JSONCodec{}
+	}
+	if j.Clock == nil {
+		j.Clock = RealClock
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segs, err := j.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segs) == 0 {
+		return j, j.createSegment(1)
+	}
+
+	if err = j.openLastSegment(segs[len(segs)-1]); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// segmentPath get the segment file path by the first sequence number
+func (j *Journal) segmentPath(firstSeq uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", firstSeq, journalExt))
+}
+
+// segments get the sorted segment file paths
+func (j *Journal) segments() ([]string, error) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segs []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), journalExt) {
+			segs = append(segs, filepath.Join(j.dir, entry.Name()))
+		}
+	}
+
+	sort.Strings(segs)
+	return segs, nil
+}
+
+// createSegment create a new segment, it is the current segment
+func (j *Journal) createSegment(firstSeq uint64) error {
+	f, err := os.OpenFile(j.segmentPath(firstSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		if err = j.closeFile(); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	j.file, j.size = f, 0
+	j.lastSeq = firstSeq - 1
+	return nil
+}
+
+// openLastSegment open the last segment for append, and recover the last sequence number
+func (j *Journal) openLastSegment(path string) error {
+	firstSeq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), journalExt), 10, 64)
+	if err != nil || firstSeq == 0 {
+		return fmt.Errorf("%w: invalid segment name %q", ErrJournalCorrupt, path)
+	}
+
+	j.lastSeq = firstSeq - 1
+	end, err := readSegment(path, -1, func(seq uint64, _ int64, _ []byte) error {
+		j.lastSeq = seq
+		return nil
+	})
+	// only the incomplete last record is truncated, the corrupt record
+	// in the middle is returned, so the valid records after it are not lost.
+	if err != nil && !errors.Is(err, errJournalTorn) {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	// truncate the incomplete record
+	if err = f.Truncate(end); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	j.file, j.size = f, end
+	return nil
+}
+
+// Append an event to the journal, return the sequence number of the record.
+func (j *Journal) Append(e IEvent) (uint64, error) {
+	bs, err := j.Codec.Encode(e)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkRecordSize(bs); err != nil {
+		return 0, err
+	}
+
+	t := j.Clock.Now()
+	if env, ok := e.(IEnvelope); ok && !env.Time().IsZero() {
+		t = env.Time()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return 0, ErrJournalClosed
+	}
+
+	recSize := int64(journalHeaderSize + journalMetaSize + len(bs))
+	if j.size > 0 && j.size+recSize > j.SegmentSize {
+		if err = j.createSegment(j.lastSeq + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	seq := j.lastSeq + 1
+	if _, err = j.file.Write(encodeRecord(seq, t.UnixNano(), bs)); err != nil {
+		// drop the partial written record
+		_ = j.file.Truncate(j.size)
+		return 0, err
+	}
+
+	j.size += recSize
+	j.lastSeq = seq
+	return seq, j.syncByPolicy()
+}
+
+// syncByPolicy fsync the current segment by the SyncPolicy
+func (j *Journal) syncByPolicy() error {
+	switch j.SyncPolicy {
+	case JournalSyncAlways:
+		return j.file.Sync()
+	case JournalSyncInterval:
+		now := j.Clock.Now()
+		if now.Sub(j.lastSync) >= j.SyncInterval {
+			j.lastSync = now
+			return j.file.Sync()
+		}
+	}
+	return nil
+}
+
+// Attach the journal to the manager as a publish hook. all published events
+// are appended to the journal, except the replayed events.
+// call the returned func to detach.
+func (j *Journal) Attach(em *Manager) func() {
+	return em.OnPublish(func(ctx context.Context, e IEvent) error {
+		if IsReplay(ctx) {
+			return nil
+		}
+
+		_, err := j.Append(e)
+		return err
+	})
+}
+
+// LastSeq get the last sequence number. it is 0 on the journal is empty.
+func (j *Journal) LastSeq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lastSeq
+}
+
+// Scan all records in order. the fn return an error will stop the scan,
+// and the error is returned.
+func (j *Journal) Scan(fn func(en *JournalEntry) error) error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return ErrJournalClosed
+	}
+	// only read the records written before the scan
+	activeSize := j.size
+	segs, err := j.segments()
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for i, path := range segs {
+		limit := int64(-1)
+		if i == len(segs)-1 {
+			limit = activeSize
+		}
+
+		_, err = readSegment(path, limit, func(seq uint64, nano int64, bs []byte) error {
+			e, err := j.Codec.Decode(bs)
+			if err != nil {
+				return fmt.Errorf("%w: decode the seq %d: %v", ErrJournalCorrupt, seq, err)
+			}
+			return fn(&JournalEntry{Seq: seq, Time: time.Unix(0, nano), Event: e})
+		})
+		if errors.Is(err, errJournalTorn) {
+			return fmt.Errorf("%w: %s", ErrJournalCorrupt, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync fsync the current segment
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrJournalClosed
+	}
+	return j.file.Sync()
+}
+
+// Close the journal, the current segment is synced.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return nil
+	}
+
+	j.closed = true
+	return j.closeFile()
+}
+
+// closeFile sync and close the current segment
+func (j *Journal) closeFile() error {
+	if err := j.file.Sync(); err != nil {
+		_ = j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+// encodeRecord encode a record of the journal format
+func encodeRecord(seq uint64, nano int64, bs []byte) []byte {
+	rec := make([]byte, journalHeaderSize+journalMetaSize+len(bs))
+	payload := rec[journalHeaderSize:]
+	binary.BigEndian.PutUint64(payload, seq)
+	binary.BigEndian.PutUint64(payload[8:], uint64(nano))
+	copy(payload[journalMetaSize:], bs)
+
+	binary.BigEndian.PutUint32(rec, uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(payload))
+	return rec
+}
+
+// checkRecordSize check the encoded event can be written as a record
+func checkRecordSize(bs []byte) error {
+	if int64(len(bs))+journalMetaSize > maxJournalRecord {
+		return fmt.Errorf("%w: %d bytes", ErrJournalRecordTooLarge, len(bs))
+	}
+	return nil
+}
+
+// readSegment read the records of a segment file, limit < 0 is read all.
+// return the end offset of the valid records.
+//
+// An incomplete last record is left by a crash on write, it will return
+// errJournalTorn. a corrupt record followed by more data will return
+// ErrJournalCorrupt, it must not be truncated.
+func readSegment(path string, limit int64, fn func(seq uint64, nano int64, bs []byte) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit)
+	}
+	br := bufio.NewReader(r)
+
+	var end int64
+	var header [journalHeaderSize]byte
+	for {
+		if _, err = io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return end, nil
+			}
+			return end, errJournalTorn
+		}
+
+		n := binary.BigEndian.Uint32(header[:])
+		if n < journalMetaSize || n > maxJournalRecord {
+			// the zero filled tail is left by a crash, others are corrupt.
+			if isZeroTail(header[:], br) {
+				return end, errJournalTorn
+			}
+			return end, fmt.Errorf("%w: invalid record length at offset %d of %s", ErrJournalCorrupt, end, path)
+		}
+
+		payload := make([]byte, n)
+		if _, err = io.ReadFull(br, payload); err != nil {
+			return end, errJournalTorn
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			// the last record is not completely synced
+			if _, err = br.Peek(1); err == io.EOF {
+				return end, errJournalTorn
+			}
+			return end, fmt.Errorf("%w: checksum mismatch at offset %d of %s", ErrJournalCorrupt, end, path)
+		}
+
+		seq := binary.BigEndian.Uint64(payload)
+		nano := int64(binary.BigEndian.Uint64(payload[8:]))
+		if err = fn(seq, nano, payload[journalMetaSize:]); err != nil {
+			return end, err
+		}
+		end += int64(journalHeaderSize + n)
+	}
+}
+
+// isZeroTail check the header and the remaining data are all zero
+func isZeroTail(header []byte, r io.Reader) bool {
+	buf := make([]byte, 4096)
+	n := copy(buf, header)
+
+	var err error
+	for {
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err != nil {
+			return err == io.EOF
+		}
+		n, err = r.Read(buf)
+	}
+}
+
+// ReplayOption the option func of the Replay
+type ReplayOption func(o *replayOptions)
+
+// replayOptions the replay options
+type replayOptions struct {
+	speed float64
+}
+
+// WithReplaySpeed replay the events at the original timing by the speed factor.
+// 1 is the original timing, 2 is double speed. default is 0, replay without waiting.
+func WithReplaySpeed(speed float64) ReplayOption {
+	return func(o *replayOptions) {
+		o.speed = speed
+	}
+}
+
+// Replay re-publish the recorded events to the manager in order, return the number
+// of the published events.
+//
+// The time range is [from, to), the zero time is not limited. the filter can be nil.
+// the replayed events are published with a context marked by replay, see IsReplay.
+// waiting on the WithReplaySpeed uses the manager Clock.
+func (j *Journal) Replay(ctx context.Context, em *Manager, from, to time.Time, filter func(en *JournalEntry) bool, opts ...ReplayOption) (int, error) {
+	opt := &replayOptions{}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	var n int
+	var prev time.Time
+	rCtx := withReplay(ctx)
+	err := j.Scan(func(en *JournalEntry) error {
+		if (!from.IsZero() && en.Time.Before(from)) || (!to.IsZero() && !en.Time.Before(to)) {
+			return nil
+		}
+		if filter != nil && !filter(en) {
+			return nil
+		}
+
+		if opt.speed > 0 && !prev.IsZero() {
+			if wait := time.Duration(float64(en.Time.Sub(prev)) / opt.speed); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-em.Clock.After(wait):
+				}
+			}
+		}
+		prev = en.Time
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := em.publishContext(rCtx, en.Event); err != nil {
+			return fmt.Errorf("event: replay the journal seq %d: %w", en.Seq, err)
+		}
+
+		n++
+		return nil
+	})
+	return n, err
+}
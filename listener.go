@@ -1,7 +1,8 @@
 package event
 
 import (
-	"fmt"
+	"context"
+	"reflect"
 	"sort"
 )
 
@@ -17,6 +18,48 @@ func (fn ListenerFunc) Handle(e IEvent) error {
 	return fn(e)
 }
 
+// IContextListener is the context-aware listener interface.
+// the manager will prefer HandleContext when a listener implements it.
+type IContextListener interface {
+	HandleContext(ctx context.Context, e IEvent) error
+}
+
+// ContextListenerFunc context-aware listener func.
+// implements the IListener and IContextListener interface
+type ContextListenerFunc func(ctx context.Context, e IEvent) error
+
+// Handle event with the background context. implements the IListener interface
+func (fn ContextListenerFunc) Handle(e IEvent) error {
+	return fn(context.Background(), e)
+}
+
+// HandleContext event. implements the IContextListener interface
+func (fn ContextListenerFunc) HandleContext(ctx context.Context, e IEvent) error {
+	return fn(ctx, e)
+}
+
+// contextAdapter adapt an IListener to IContextListener
+type contextAdapter struct {
+	IListener
+}
+
+// HandleContext check the context is done, then call the listener.
+func (a contextAdapter) HandleContext(ctx context.Context, e IEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Handle(e)
+}
+
+// ToContextListener adapt an IListener to IContextListener.
+// if the listener is already an IContextListener, will return it.
+func ToContextListener(l IListener) IContextListener {
+	if cl, ok := l.(IContextListener); ok {
+		return cl
+	}
+	return contextAdapter{l}
+}
+
 // ISubscriber is the event subscriber interface.
 // you can register multi event listeners in a struct func.
 type ISubscriber interface {
@@ -28,8 +71,49 @@ type ISubscriber interface {
 
 // ListenerItem storage an event listener and it's priority value.
 type ListenerItem struct {
+	// ID unique listener ID in the manager. it is set on add to the manager.
+	ID uint64
+	// Name an optional stable name of the listener, it should be unique for
+	// the event name. the dead letters are redelivered by it after restart.
+	Name     string
 	Priority int
 	Listener IListener
+	// Retry the retry policy on the listener return error. nil is no retry.
+	Retry *RetryPolicy
+	// RateLimit limit the listener call rate. nil is no limit.
+	RateLimit *RateLimiter
+}
+
+// Subscription is the handle of a registered listener.
+type Subscription struct {
+	em   *Manager
+	id   uint64
+	name string
+}
+
+// ID get the listener ID
+func (s *Subscription) ID() uint64 {
+	return s.id
+}
+
+// Name get the listened event name
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Unsubscribe remove the listener from the manager
+func (s *Subscription) Unsubscribe() {
+	s.em.removeListenerByID(s.name, s.id)
+}
+
+// Subscriptions is a group of Subscription
+type Subscriptions []*Subscription
+
+// Unsubscribe remove all listeners of the group
+func (ss Subscriptions) Unsubscribe() {
+	for _, s := range ss {
+		s.Unsubscribe()
+	}
 }
 
 // ListenerQueue storage sorted IListener instance.
@@ -54,7 +138,7 @@ func (lq *ListenerQueue) Push(li *ListenerItem) *ListenerQueue {
 }
 
 // Sort the queue items by ListenerItem's priority.
-// Priority: High > Low
+// Priority: High > Low. the items with same priority keep the add order.
 func (lq *ListenerQueue) Sort() *ListenerQueue {
 	// if lq.IsEmpty() {
 	// 	return lq
@@ -63,7 +147,7 @@ func (lq *ListenerQueue) Sort() *ListenerQueue {
 
 	// check items is sorted
 	if !sort.IsSorted(ls) {
-		sort.Sort(ls)
+		sort.Stable(ls)
 	}
 
 	return lq
@@ -74,25 +158,98 @@ func (lq *ListenerQueue) Items() []*ListenerItem {
 	return lq.items
 }
 
-// Remove a listener from the queue
+// Remove a listener from the queue.
+//
+// NOTE: func listeners can not be compared, all closures from the same func
+// literal are considered the same. use the Subscription or RemoveByID instead.
 func (lq *ListenerQueue) Remove(listener IListener) {
 	if listener == nil {
 		return
 	}
 
-	ptrVal := fmt.Sprintf("%p", listener)
+	for _, id := range lq.findIDs(listener) {
+		lq.RemoveByID(id)
+	}
+}
 
-	var newItems []*ListenerItem
+// RemoveByID remove a listener by the listener ID. return false if not found.
+func (lq *ListenerQueue) RemoveByID(id uint64) bool {
+	for i, li := range lq.items {
+		if li.ID == id {
+			lq.items = append(lq.items[:i:i], lq.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// findIDs find the IDs of the items with the same listener
+func (lq *ListenerQueue) findIDs(listener IListener) (ids []uint64) {
 	for _, li := range lq.items {
-		liPtrVal := fmt.Sprintf("%p", li.Listener)
-		if liPtrVal == ptrVal {
-			continue
+		if isSameListener(li.Listener, listener) {
+			ids = append(ids, li.ID)
+		}
+	}
+	return
+}
+
+// isSameListener check two listeners is same.
+// comparable value use ==, func/map/slice use the pointer value.
+func isSameListener(a, b IListener) (same bool) {
+	// == will panic on the struct contains an uncomparable value
+	defer func() {
+		if recover() != nil {
+			same = false
 		}
+	}()
 
-		newItems = append(newItems, li)
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
 	}
 
-	lq.items = newItems
+	if ta.Comparable() {
+		return a == b
+	}
+
+	switch ta.Kind() {
+	case reflect.Func, reflect.Map, reflect.Slice:
+		return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+	}
+	return false
+}
+
+// clone create a new queue with the same items
+func (lq *ListenerQueue) clone() *ListenerQueue {
+	return &ListenerQueue{items: append([]*ListenerItem(nil), lq.items...)}
+}
+
+// withItem create a new queue with the item inserted by priority.
+// the item is placed after the items that have the same priority.
+func (lq *ListenerQueue) withItem(li *ListenerItem) *ListenerQueue {
+	pos := sort.Search(len(lq.items), func(i int) bool {
+		return lq.items[i].Priority < li.Priority
+	})
+
+	items := make([]*ListenerItem, 0, len(lq.items)+1)
+	items = append(items, lq.items[:pos]...)
+	items = append(items, li)
+	items = append(items, lq.items[pos:]...)
+	return &ListenerQueue{items: items}
+}
+
+// withoutID create a new queue without the item of the ID.
+// if not found, will return the queue self.
+func (lq *ListenerQueue) withoutID(id uint64) *ListenerQueue {
+	for i, li := range lq.items {
+		if li.ID == id {
+			items := make([]*ListenerItem, 0, len(lq.items)-1)
+			items = append(items, lq.items[:i]...)
+			items = append(items, lq.items[i+1:]...)
+			return &ListenerQueue{items: items}
+		}
+	}
+	return lq
 }
 
 // Clear all listeners of ListenerQueue
@@ -1,122 +1,315 @@
 package event
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
 // IManager event manager interface
 type IManager interface {
 	AddEvent(IEvent)
-	Listen(name string, listener IListener, priority ...int)
+	Listen(name string, listener IListener, priority ...int) *Subscription
 	Publish(name string, params M) (error, IEvent)
 }
 
+// There are some recover policies for the listener panic
+const (
+	// RecoverNone do not recover, the panic will be raised to the caller
+	RecoverNone = iota
+	// RecoverStop recover the panic and stop the chain, return a *PanicError
+	RecoverStop
+	// RecoverSkip recover the panic and skip the listener. the *PanicError
+	// will be reported to the ErrorHandler
+	RecoverSkip
+	// RecoverContinue recover the panic and continue the chain, the *PanicError
+	// will be returned after the chain is done
+	RecoverContinue
+)
+
+// Options event manager config options
+type Options struct {
+	// EnableLock enable lock on publish event.
+	EnableLock bool
+	// ConsumerNum the number of async publish workers. default is runtime.NumCPU()
+	ConsumerNum int
+	// ChannelSize the async publish queue size. default is 100
+	ChannelSize int
+	// Overflow policy on the async queue is full. default is OverflowBlock
+	Overflow int
+	// ErrorHandler receive errors on async publish
+	ErrorHandler func(e IEvent, err error)
+	// UnifiedPriority merge the exact, pattern and wildcard listeners into
+	// one chain ordered by priority. default is False, they are called by group.
+	UnifiedPriority bool
+	// RecoverPolicy the policy on a listener panic. default is RecoverNone
+	RecoverPolicy int
+	// ContinueOnError call all matched listeners even if some of them return
+	// error, the errors are aggregated to a *ListenerErrors.
+	// Abort and the context done still stop the chain.
+	ContinueOnError bool
+	// Clock the time source for retry backoff. default is RealClock
+	Clock Clock
+	// OnRetry is called before a failed listener is retried.
+	// attempt is the failed attempt number, start from 1.
+	OnRetry func(e IEvent, li *ListenerItem, attempt int, err error)
+	// DeadLetter receive the events that a listener failed after all retries
+	DeadLetter DeadLetterSink
+	// Outbox persist the events of the committing Tx, for redeliver after a crash
+	Outbox OutboxStore
+}
+
+// OptionFn event manager config option func
+type OptionFn func(o *Options)
+
 // Manager definition event manager. for manage events and listeners
+//
+// It is safe to register listeners from any goroutine. the publish will
+// read an immutable registry snapshot, no lock is required.
 type Manager struct {
+	Options
+	// lock on publish event, when EnableLock is true.
 	sync.Mutex
-	// enable lock on publish event.
-	EnableLock bool
-	// name of the manager
-	name string
 	// it's a sample for new BasicEvent
 	sample *BasicEvent
-	// storage user custom IEvent instance. you can pre-define some IEvent instances.
-	events map[string]IEvent
-	// key is the event name, value is the queue of listener func
-	listeners map[string]*ListenerQueue
-	// storage all event names by listened
-	listenedNames map[string]int
+	// mu lock for modify the registry
+	mu sync.Mutex
+	// reg storage the current *registry snapshot
+	reg atomic.Value
+	// the last listener ID
+	lastID uint64
+	// instance a unique ID of the manager, the listener IDs are only valid in it.
+	instance string
+	// counters of the manager
+	stats *Stats
+
+	// async publish queue and workers
+	queue     chan IEvent
+	queueMu   sync.RWMutex
+	workers   sync.WaitGroup
+	startOnce sync.Once
+	closed    bool
+	// the in-flight AsyncPublish senders
+	senders sync.WaitGroup
+	// closed on Close, to stop the blocked senders
+	closing chan struct{}
+
+	// the outbox entry IDs being delivered, skipped by the RelayOutbox
+	outboxInflight sync.Map
+	// the removed ListenTimes listeners that wrap a Flusher, flushed on Close
+	removedFlushers sync.Map
+
+	// scheduler for the delayed events
+	sched     *scheduler
+	schedOnce sync.Once
 }
 
 // NewManager create event manager
-func NewManager(name string) *Manager {
-	return &Manager{
-		name:          name,
-		sample:        &BasicEvent{},
-		events:        make(map[string]IEvent),
-		listeners:     make(map[string]*ListenerQueue),
-		listenedNames: make(map[string]int),
+func NewManager(name string, fns ...OptionFn) *Manager {
+	em := &Manager{
+		instance: NewID(),
+		sample:   &BasicEvent{},
+		stats:    &Stats{},
+		closing:  make(chan struct{}),
+	}
+
+	// the name is stored in the registry, so Reset can clear it safely.
+	r := newRegistry()
+	r.name = name
+	em.reg.Store(r)
+
+	em.ConsumerNum = runtime.NumCPU()
+	em.ChannelSize = 100
+	em.Clock = RealClock
+	for _, fn := range fns {
+		fn(&em.Options)
+	}
+
+	if em.ConsumerNum < 1 {
+		em.ConsumerNum = 1
+	}
+	if em.ChannelSize < 0 {
+		em.ChannelSize = 0
+	}
+	if em.Clock == nil {
+		em.Clock = RealClock
+	}
+	return em
+}
+
+// WithConsumerNum set the async publish workers number
+func WithConsumerNum(num int) OptionFn {
+	return func(o *Options) {
+		o.ConsumerNum = num
+	}
+}
+
+// WithChannelSize set the async publish queue size
+func WithChannelSize(size int) OptionFn {
+	return func(o *Options) {
+		o.ChannelSize = size
+	}
+}
+
+// WithOverflow set the policy on the async queue is full
+func WithOverflow(policy int) OptionFn {
+	return func(o *Options) {
+		o.Overflow = policy
+	}
+}
+
+// WithErrorHandler set the async error handler
+func WithErrorHandler(fn func(e IEvent, err error)) OptionFn {
+	return func(o *Options) {
+		o.ErrorHandler = fn
+	}
+}
+
+// WithUnifiedPriority order all matched listeners by priority globally
+func WithUnifiedPriority(o *Options) {
+	o.UnifiedPriority = true
+}
+
+// WithRecoverPolicy set the policy on a listener panic
+func WithRecoverPolicy(policy int) OptionFn {
+	return func(o *Options) {
+		o.RecoverPolicy = policy
+	}
+}
+
+// WithContinueOnError call all listeners on error, and aggregate the errors
+func WithContinueOnError(o *Options) {
+	o.ContinueOnError = true
+}
+
+// WithClock set the time source of the manager
+func WithClock(c Clock) OptionFn {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// WithOnRetry set the hook on a listener will be retried
+func WithOnRetry(fn func(e IEvent, li *ListenerItem, attempt int, err error)) OptionFn {
+	return func(o *Options) {
+		o.OnRetry = fn
+	}
+}
+
+// WithDeadLetter set the sink for failed events
+func WithDeadLetter(sink DeadLetterSink) OptionFn {
+	return func(o *Options) {
+		o.DeadLetter = sink
+	}
+}
+
+// WithOutbox set the outbox store of the Tx
+func WithOutbox(store OutboxStore) OptionFn {
+	return func(o *Options) {
+		o.Outbox = store
 	}
 }
 
 // Listen register an event handler/listener with priority.
 // if not, default level is NORMAL
-func (em *Manager) Listen(name string, listener IListener, priority ...int) {
+func (em *Manager) Listen(name string, listener IListener, priority ...int) *Subscription {
 	pv := Normal
 	if len(priority) > 0 {
 		pv = priority[0]
 	}
 
-	em.addListenerItem(name, &ListenerItem{pv, listener})
+	return em.addListenerItem(name, &ListenerItem{Priority: pv, Listener: listener})
+}
+
+// ListenItem register a listener item. it can config the priority, retry policy.
+func (em *Manager) ListenItem(name string, li *ListenerItem) *Subscription {
+	return em.addListenerItem(name, li)
 }
 
 // Subscribe add events by ISubscriber interface.
 // you can register multi event listeners in a struct func.
-func (em *Manager) Subscribe(s ISubscriber) {
+func (em *Manager) Subscribe(s ISubscriber) Subscriptions {
+	var ss Subscriptions
 	for name, listener := range s.SubscribedEvents() {
 		switch lt := listener.(type) {
 		case IListener:
-			em.Listen(name, lt)
+			ss = append(ss, em.Listen(name, lt))
 		case ListenerItem:
-			em.addListenerItem(name, &lt)
+			ss = append(ss, em.addListenerItem(name, &lt))
 		default:
 			panic("event: the value must be an IListener or ListenerItem instance")
 		}
 	}
+	return ss
 }
 
-func (em *Manager) addListenerItem(name string, li *ListenerItem) {
-	if name != Wildcard {
-		name = checkName(name)
-	}
-
+func (em *Manager) addListenerItem(name string, li *ListenerItem) *Subscription {
+	name = checkPattern(name)
 	if li.Listener == nil {
 		panic("event: the event '" + name + "' listener cannot be empty")
 	}
 
-	// if exists, append it.
-	if lq, ok := em.listeners[name]; ok {
-		lq.Push(li)
-	} else { // first add.
-		em.listenedNames[name] = 1
-		em.listeners[name] = (&ListenerQueue{}).Push(li)
-	}
+	li.ID = atomic.AddUint64(&em.lastID, 1)
+	em.update(func(r *registry) {
+		r.addItem(name, li)
+	})
+	return &Subscription{em: em, id: li.ID, name: name}
+}
+
+// registry get the current registry snapshot. DO NOT modify it.
+func (em *Manager) registry() *registry {
+	return em.reg.Load().(*registry)
+}
+
+// update the registry by copy-on-write. fn will receive a cloned registry.
+func (em *Manager) update(fn func(r *registry)) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	r := em.registry().clone()
+	fn(r)
+	r.build()
+	em.reg.Store(r)
 }
 
 // Publish event by name. if not found listener, will return (nil, nil)
 func (em *Manager) Publish(name string, params M) (err error, e IEvent) {
-	name = checkName(name)
+	return em.PublishContext(context.Background(), name, params)
+}
 
-	// must check the '*' global listeners
-	if false == em.HasListeners(name) && false == em.HasListeners(Wildcard) {
-		// has group listeners. "app.*" "aa.bb.*"
-		// eg: "aa.bb.cc" will trigger listeners on the "aa.bb.*"
-		pos := strings.LastIndexByte(name, '.')
-		if pos < 0 || pos == len(name)-1 {
-			return // not found listeners.
-		}
+// PublishContext publish event by name with context.
+// the remaining listeners will not be called once the ctx is done, and return ctx.Err()
+func (em *Manager) PublishContext(ctx context.Context, name string, params M) (err error, e IEvent) {
+	name = checkName(name)
+	r := em.registry()
 
-		groupName := name[:pos+1] + Wildcard // "aa.bb.*"
-		if false == em.HasListeners(groupName) {
-			return // not found listeners.
-		}
+	// check the exact, pattern and '*' global listeners.
+	// the publish hooks are called even if no listeners.
+	if len(r.hooks) == 0 && len(r.match(name, em.UnifiedPriority)) == 0 {
+		return // not found listeners.
 	}
 
 	// call listeners use defined IEvent
-	if e, ok := em.events[name]; ok {
+	if e, ok := r.events[name]; ok {
 		if params != nil {
 			e.SetData(params)
 		}
+		// the defined event is reused, stamp a new ID and time on each publish.
+		if rs, ok := e.(envelopeResetter); ok {
+			rs.resetEnvelope()
+		}
 
-		err = em.publish(e)
+		err = em.publishContext(ctx, e)
 		return err, e
 	}
 
 	// create a basic event instance
 	e = em.copyBasicEvent(name, params)
 	// call listeners handle event
-	err = em.publish(e)
+	err = em.publishContext(ctx, e)
 	return
 }
 
@@ -129,36 +322,53 @@ func (em *Manager) MustPublish(name string, params M) IEvent {
 	return e
 }
 
-// AsyncPublish async publish event by 'go' keywords
-func (em *Manager) AsyncPublish(e IEvent) {
-	go func(e IEvent) {
-		_ = em.publish(e)
-	}(e)
-}
-
 // AwaitPublish async publish event by 'go' keywords, but will wait return result
 func (em *Manager) AwaitPublish(e IEvent) (err error) {
-	ch := make(chan error)
+	return em.AwaitPublishContext(context.Background(), e)
+}
+
+// AwaitPublishContext async publish event by 'go' keywords, and wait the result
+// until the ctx is done. on ctx done, will return ctx.Err() and the remaining
+// listeners will not be called.
+func (em *Manager) AwaitPublishContext(ctx context.Context, e IEvent) (err error) {
+	// buffered, the goroutine can exit after the ctx is done.
+	ch := make(chan error, 1)
 
 	go func(e IEvent) {
-		err := em.publish(e)
-		ch <- err
+		ch <- em.publishContext(ctx, e)
 	}(e)
 
-	err = <-ch
-	close(ch)
+	select {
+	case err = <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
 	return
 }
 
 // BatchPublish publish multi event at once.
+// all events will be published, and the errors are collected in order.
+// with the ContinueOnError, each error is a *ListenerErrors of the event.
+//
 // eg. BatchPublish("name1", "name2", &MyEvent{})
 func (em *Manager) BatchPublish(es ...interface{}) (ers []error) {
+	return em.BatchPublishContext(context.Background(), es...)
+}
+
+// BatchPublishContext publish multi event at once with context.
+// will stop publish the remaining events once the ctx is done.
+func (em *Manager) BatchPublishContext(ctx context.Context, es ...interface{}) (ers []error) {
 	var err error
 	for _, e := range es {
+		if err = ctx.Err(); err != nil {
+			ers = append(ers, err)
+			return
+		}
+
 		if name, ok := e.(string); ok {
-			err, _ = em.Publish(name, nil)
+			err, _ = em.PublishContext(ctx, name, nil)
 		} else if evt, ok := e.(IEvent); ok {
-			err = em.publish(evt)
+			err = em.publishContext(ctx, evt)
 		}
 
 		if err != nil {
@@ -169,81 +379,152 @@ func (em *Manager) BatchPublish(es ...interface{}) (ers []error) {
 }
 
 func (em *Manager) publish(e IEvent) (err error) {
+	return em.publishContext(context.Background(), e)
+}
+
+func (em *Manager) publishContext(ctx context.Context, e IEvent) (err error) {
 	if em.EnableLock {
 		em.Lock()
 		defer em.Unlock()
 	}
 
 	e.Abort(false)
+	em.stampEnvelope(ctx, e)
 	name := e.Name()
+	r := em.registry()
 
-	// find matched listeners
-	lq, ok := em.listeners[name]
-	if ok {
-		// sort by priority before call.
-		for _, li := range lq.Sort().Items() {
-			err = li.Listener.Handle(e)
-			if err != nil || e.IsAborted() {
-				return
-			}
+	// check the publish rate limits
+	if err = em.checkRateLimit(ctx, r, name); err != nil {
+		return
+	}
+
+	if len(r.hooks) > 0 {
+		if err = em.callHooks(ctx, r, e); err != nil {
+			return
 		}
 	}
 
-	// has group listeners.
-	pos := strings.LastIndexByte(name, '.')
-	if pos > 0 && pos < len(name) {
-		groupName := name[:pos+1] + Wildcard
+	// collected errors on ContinueOnError or RecoverContinue
+	var errs []*ListenerError
 
-		if lq, ok := em.listeners[groupName]; ok {
-			for _, li := range lq.Sort().Items() {
-				err = li.Listener.Handle(e)
-				if err != nil || e.IsAborted() {
-					return
-				}
+	// the matched listeners are resolved and cached by the registry.
+	for _, li := range r.match(name, em.UnifiedPriority) {
+		allowed, lErr := em.checkListenerLimit(ctx, li, e)
+		if !allowed && lErr == nil {
+			continue // skipped by the listener rate limit
+		}
+
+		var recovered bool
+		if allowed {
+			var attempts int
+			lErr, recovered, attempts = em.invokeWithRetry(ctx, li, e)
+			if lErr != nil && ctx.Err() == nil {
+				em.putDeadLetter(e, li, lErr, attempts)
 			}
 		}
-	}
 
-	// has wildcard event listeners
-	if lq, ok := em.listeners[Wildcard]; ok {
-		for _, li := range lq.Sort().Items() {
-			err = li.Listener.Handle(e)
-			if err != nil || e.IsAborted() {
+		if lErr != nil && recovered && em.RecoverPolicy == RecoverSkip {
+			em.handleError(e, lErr)
+			lErr = nil
+		}
+
+		if lErr != nil && (em.ContinueOnError || recovered && em.RecoverPolicy == RecoverContinue) {
+			errs = append(errs, &ListenerError{EventName: name, ListenerID: li.ID, Err: lErr})
+
+			// the ctx is done, stop the chain.
+			if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(lErr, ctxErr) {
 				break
 			}
+			lErr = nil
+		}
+
+		if lErr != nil {
+			err = lErr
+			break
 		}
+		if e.IsAborted() {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return
+	}
+	if em.ContinueOnError {
+		return &ListenerErrors{Errors: errs}
+	}
+
+	// on RecoverContinue, return the first panic error
+	if err == nil {
+		err = errs[0].Err
 	}
 	return
 }
 
-// AddEvent add a defined event instance to manager.
+// invoke the listener, will recover the panic by the RecoverPolicy.
+func (em *Manager) invoke(ctx context.Context, li *ListenerItem, e IEvent) (err error, recovered bool) {
+	if em.RecoverPolicy != RecoverNone {
+		defer func() {
+			if val := recover(); val != nil {
+				err = &PanicError{
+					EventName:  e.Name(),
+					ListenerID: li.ID,
+					Value:      val,
+					Stack:      debug.Stack(),
+				}
+				recovered = true
+			}
+		}()
+	}
+
+	return callListener(ctx, li, e), false
+}
+
+// AddEvent add a defined event instance to manager. the instance is reused
+// on each publish by name, it is not safe to publish it concurrently.
 func (em *Manager) AddEvent(e IEvent) {
 	name := checkName(e.Name())
-	em.events[name] = e
+	em.update(func(r *registry) {
+		r.events[name] = e
+	})
 }
 
 // GetEvent get a defined event instance by name
 func (em *Manager) GetEvent(name string) (e IEvent, ok bool) {
-	e, ok = em.events[name]
+	e, ok = em.registry().events[name]
 	return
 }
 
 // HasEvent has event check
 func (em *Manager) HasEvent(name string) bool {
-	_, ok := em.events[name]
+	_, ok := em.registry().events[name]
 	return ok
 }
 
 // RemoveEvent delete IEvent by name
 func (em *Manager) RemoveEvent(name string) {
-	if _, ok := em.events[name]; ok {
-		delete(em.events, name)
+	if !em.HasEvent(name) {
+		return
 	}
+
+	em.update(func(r *registry) {
+		delete(r.events, name)
+	})
 }
 
 // RemoveEvents remove all registered events
 func (em *Manager) RemoveEvents() {
-	em.events = map[string]IEvent{}
+	em.update(func(r *registry) {
+		r.events = map[string]IEvent{}
+	})
+}
+
+// stampEnvelope fill the empty envelope fields of the event: ID, time, source.
+// if the ctx has a cause event, will set the causation and correlation ID.
+func (em *Manager) stampEnvelope(ctx context.Context, e IEvent) {
+	if s, ok := e.(envelopeStamper); ok {
+		s.stampEnvelope(em.registry().name, em.Clock.Now(), causeFromContext(ctx))
+	}
 }
 
 // copyBasicEvent create new BasicEvent by clone em.sample
@@ -257,31 +538,51 @@ func (em *Manager) copyBasicEvent(name string, data M) *BasicEvent {
 
 // HasListeners has listeners for the event name.
 func (em *Manager) HasListeners(name string) bool {
-	_, ok := em.listenedNames[name]
-	return ok
+	return em.registry().hasListeners(name)
 }
 
-// Listeners get all listeners
+// Listeners get all listeners. the returned queues are copies,
+// modify them will not change the manager.
 func (em *Manager) Listeners() map[string]*ListenerQueue {
-	return em.listeners
+	ls := em.registry().listeners
+	cp := make(map[string]*ListenerQueue, len(ls))
+	for name, lq := range ls {
+		cp[name] = lq.clone()
+	}
+	return cp
 }
 
-// ListenersByName get listeners by given event name
+// ListenersByName get listeners by given event name. the returned queue is
+// a copy, return nil if not found.
 func (em *Manager) ListenersByName(name string) *ListenerQueue {
-	return em.listeners[name]
+	if lq, ok := em.registry().listeners[name]; ok {
+		return lq.clone()
+	}
+	return nil
+}
+
+// ListenersFor get all listeners that will handle the event name, in call order.
+// it contains the exact, pattern and wildcard listeners. the returned slice is read-only.
+func (em *Manager) ListenersFor(name string) []*ListenerItem {
+	return em.registry().match(name, em.UnifiedPriority)
 }
 
 // ListenersCount get listeners number for the event name.
 func (em *Manager) ListenersCount(name string) int {
-	if lq, ok := em.listeners[name]; ok {
+	if lq, ok := em.registry().listeners[name]; ok {
 		return lq.Len()
 	}
 	return 0
 }
 
-// ListenedNames get listened event names
+// ListenedNames get listened event names. the returned map is a copy.
 func (em *Manager) ListenedNames() map[string]int {
-	return em.listenedNames
+	names := em.registry().listenedNames
+	cp := make(map[string]int, len(names))
+	for name, v := range names {
+		cp[name] = v
+	}
+	return cp
 }
 
 // RemoveListener remove a given listener, you can limit event name.
@@ -290,53 +591,64 @@ func (em *Manager) ListenedNames() map[string]int {
 // 	RemoveListener("", listener)
 // 	RemoveListener("name", listener) // limit event name.
 func (em *Manager) RemoveListener(name string, listener IListener) {
-	if name != "" {
-		if lq, ok := em.listeners[name]; ok {
-			lq.Remove(listener)
-
-			// delete from manager
-			if lq.IsEmpty() {
-				delete(em.listeners, name)
-				delete(em.listenedNames, name)
-			}
-		}
+	if listener == nil {
 		return
 	}
 
-	// name is empty. find all listener and remove matched.
-	for name, lq := range em.listeners {
-		lq.Remove(listener)
+	em.update(func(r *registry) {
+		if name != "" {
+			if lq, ok := r.listeners[name]; ok {
+				for _, id := range lq.findIDs(listener) {
+					r.removeItem(name, id)
+				}
+			}
+			return
+		}
 
-		// delete from manager
-		if lq.IsEmpty() {
-			delete(em.listeners, name)
-			delete(em.listenedNames, name)
+		// name is empty. find all listener and remove matched.
+		for name, lq := range r.listeners {
+			for _, id := range lq.findIDs(listener) {
+				r.removeItem(name, id)
+			}
 		}
-	}
+	})
+}
+
+// removeListenerByID remove a listener by event name and listener ID
+func (em *Manager) removeListenerByID(name string, id uint64) {
+	em.update(func(r *registry) {
+		r.removeItem(name, id)
+	})
 }
 
 // RemoveListeners remove listeners by given name
 func (em *Manager) RemoveListeners(name string) {
-	_, ok := em.listenedNames[name]
-	if ok {
-		em.listeners[name].Clear()
-
-		// delete from manager
-		delete(em.listeners, name)
-		delete(em.listenedNames, name)
+	if !em.HasListeners(name) {
+		return
 	}
+
+	em.update(func(r *registry) {
+		r.removeName(name)
+	})
 }
 
 // Reset the manager, clear all data.
 func (em *Manager) Reset() {
-	// clear all listeners
-	for _, lq := range em.listeners {
-		lq.Clear()
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	// reset all, the name is cleared too.
+	em.reg.Store(newRegistry())
+}
+
+// callListener call the listener with context. if the ctx is done, will return ctx.Err()
+func callListener(ctx context.Context, li *ListenerItem, e IEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// reset all
-	em.name = ""
-	em.events = make(map[string]IEvent)
-	em.listeners = make(map[string]*ListenerQueue)
-	em.listenedNames = make(map[string]int)
+	if cl, ok := li.Listener.(IContextListener); ok {
+		return cl.HandleContext(ctx, e)
+	}
+	return li.Listener.Handle(e)
 }
@@ -0,0 +1,92 @@
+package event
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DeepWildcard match one or more segments in a pattern. eg: "app.**"
+const DeepWildcard = "**"
+
+// regex for check good event name pattern.
+// the "*" and "**" must be a whole segment. eg: "order.*.created", "*.created", "order.**"
+var patternReg = regexp.MustCompile(`^(\*\*?|[a-zA-Z][\w-]*)(\.(\*\*?|[\w-]+))*$`)
+
+// isPattern check the event name is a pattern
+func isPattern(name string) bool {
+	return strings.IndexByte(name, '*') >= 0
+}
+
+// checkPattern check the listened event name or pattern.
+func checkPattern(name string) string {
+	name = strings.TrimSpace(name)
+	if name == Wildcard {
+		return name
+	}
+	if !isPattern(name) {
+		return checkName(name)
+	}
+
+	if !patternReg.MatchString(name) {
+		panic("event: the event pattern '" + name + "' is invalid, the '*' and '**' must be a whole segment")
+	}
+	return name
+}
+
+// matchName check the event name is matched the pattern.
+//
+// Rules:
+// 	"*"            match all event names
+// 	"order.*"      "*" match one segment. eg: "order.created"
+// 	"order.*.paid" "*" can be at anywhere. eg: "order.123.paid"
+// 	"order.**"     "**" match one or more segments. eg: "order.created", "order.a.b"
+func matchName(pattern, name string) bool {
+	if pattern == Wildcard {
+		return true
+	}
+	return matchSegments(pattern, name)
+}
+
+func matchSegments(pattern, name string) bool {
+	pSeg, pRest, pHas := cutSegment(pattern)
+	nSeg, nRest, nHas := cutSegment(name)
+
+	switch pSeg {
+	case DeepWildcard:
+		// "**" at the end, match all remaining segments.
+		if !pHas {
+			return true
+		}
+
+		// "**" consume one or more segments
+		for nHas {
+			if matchSegments(pRest, nRest) {
+				return true
+			}
+			_, nRest, nHas = cutSegment(nRest)
+		}
+		return false
+	case Wildcard:
+		// match any one segment
+	default:
+		if pSeg != nSeg {
+			return false
+		}
+	}
+
+	if pHas != nHas {
+		return false
+	}
+	if !pHas {
+		return true
+	}
+	return matchSegments(pRest, nRest)
+}
+
+// cutSegment cut the first segment of the name by '.'
+func cutSegment(s string) (seg, rest string, hasRest bool) {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
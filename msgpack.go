@@ -0,0 +1,538 @@
+package event
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// errMsgpackShort the msgpack data is too short
+var errMsgpackShort = errors.New("event: msgpack: unexpected end of data")
+
+// MsgpackCodec encode the events as MessagePack.
+//
+// Support the values: nil, bool, ints, uints, floats, string, []byte, time.Time,
+// slices and maps with string keys. the integers will be decoded as int64
+// (uint64 on overflow int64), slices as []interface{} and maps as M.
+type MsgpackCodec struct {
+	// Types the event types for decode, default is the DefaultTypes
+	Types *TypeRegistry
+}
+
+// Name of the codec
+func (c *MsgpackCodec) Name() string {
+	return "msgpack"
+}
+
+// Encode the event
+func (c *MsgpackCodec) Encode(e IEvent) ([]byte, error) {
+	r, err := typesOrDefault(c.Types).ToRecord(e)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(M, 10)
+	fields["name"] = r.Name
+	putIf := func(key, val string) {
+		if val != "" {
+			fields[key] = val
+		}
+	}
+	putIf("type", r.Type)
+	putIf("id", r.ID)
+	putIf("source", r.Source)
+	putIf("correlation_id", r.CorrelationID)
+	putIf("causation_id", r.CausationID)
+	if len(r.Data) > 0 {
+		fields["data"] = r.Data
+	}
+	if len(r.Meta) > 0 {
+		fields["meta"] = r.Meta
+	}
+	if !r.Time.IsZero() {
+		fields["time"] = r.Time
+	}
+	if len(r.Payload) > 0 {
+		fields["payload"] = []byte(r.Payload)
+	}
+
+	enc := &msgpackEncoder{}
+	if err = enc.encode(fields); err != nil {
+		return nil, err
+	}
+	return enc.buf, nil
+}
+
+// Decode the event
+func (c *MsgpackCodec) Decode(bs []byte) (IEvent, error) {
+	dec := &msgpackDecoder{buf: bs}
+	val, err := dec.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	fields, ok := val.(M)
+	if !ok {
+		return nil, errors.New("event: msgpack: the event record must be a map")
+	}
+
+	r := &EventRecord{}
+	str := func(key string) string {
+		s, _ := fields[key].(string)
+		return s
+	}
+	r.Type, r.Name, r.ID, r.Source = str("type"), str("name"), str("id"), str("source")
+	r.CorrelationID, r.CausationID = str("correlation_id"), str("causation_id")
+	r.Data, _ = fields["data"].(M)
+	r.Meta, _ = fields["meta"].(M)
+	r.Time, _ = fields["time"].(time.Time)
+	if bs, ok := fields["payload"].([]byte); ok {
+		r.Payload = bs
+	}
+
+	return typesOrDefault(c.Types).FromRecord(r)
+}
+
+// msgpackEncoder a minimal MessagePack encoder
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (enc *msgpackEncoder) byte1(b byte) {
+	enc.buf = append(enc.buf, b)
+}
+
+func (enc *msgpackEncoder) uint16(code byte, n uint16) {
+	enc.buf = append(enc.buf, code, 0, 0)
+	binary.BigEndian.PutUint16(enc.buf[len(enc.buf)-2:], n)
+}
+
+func (enc *msgpackEncoder) uint32(code byte, n uint32) {
+	enc.buf = append(enc.buf, code, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(enc.buf[len(enc.buf)-4:], n)
+}
+
+func (enc *msgpackEncoder) uint64(code byte, n uint64) {
+	enc.buf = append(enc.buf, code, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(enc.buf[len(enc.buf)-8:], n)
+}
+
+func (enc *msgpackEncoder) encode(val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		enc.byte1(0xc0)
+	case bool:
+		if v {
+			enc.byte1(0xc3)
+		} else {
+			enc.byte1(0xc2)
+		}
+	case int:
+		enc.int(int64(v))
+	case int8:
+		enc.int(int64(v))
+	case int16:
+		enc.int(int64(v))
+	case int32:
+		enc.int(int64(v))
+	case int64:
+		enc.int(v)
+	case uint:
+		enc.uint(uint64(v))
+	case uint8:
+		enc.uint(uint64(v))
+	case uint16:
+		enc.uint(uint64(v))
+	case uint32:
+		enc.uint(uint64(v))
+	case uint64:
+		enc.uint(v)
+	case float32:
+		enc.uint32(0xca, math.Float32bits(v))
+	case float64:
+		enc.uint64(0xcb, math.Float64bits(v))
+	case string:
+		enc.str(v)
+	case []byte:
+		enc.bin(v)
+	case time.Time:
+		enc.time(v)
+	case map[string]interface{}:
+		return enc.mapStr(v)
+	case []interface{}:
+		enc.arrayLen(len(v))
+		for _, item := range v {
+			if err := enc.encode(item); err != nil {
+				return err
+			}
+		}
+	default:
+		return enc.reflectValue(reflect.ValueOf(val))
+	}
+	return nil
+}
+
+// reflectValue encode the slices and maps with string keys
+func (enc *msgpackEncoder) reflectValue(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		enc.arrayLen(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+
+		m := make(M, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return enc.mapStr(m)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			enc.byte1(0xc0)
+			return nil
+		}
+		return enc.encode(rv.Elem().Interface())
+	}
+	return fmt.Errorf("event: msgpack: unsupported type %s", rv.Type())
+}
+
+func (enc *msgpackEncoder) int(n int64) {
+	switch {
+	case n >= 0:
+		enc.uint(uint64(n))
+	case n >= -32:
+		enc.byte1(byte(n))
+	case n >= math.MinInt8:
+		enc.buf = append(enc.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		enc.uint16(0xd1, uint16(n))
+	case n >= math.MinInt32:
+		enc.uint32(0xd2, uint32(n))
+	default:
+		enc.uint64(0xd3, uint64(n))
+	}
+}
+
+func (enc *msgpackEncoder) uint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		enc.byte1(byte(n))
+	case n <= math.MaxUint8:
+		enc.buf = append(enc.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		enc.uint16(0xcd, uint16(n))
+	case n <= math.MaxUint32:
+		enc.uint32(0xce, uint32(n))
+	default:
+		enc.uint64(0xcf, n)
+	}
+}
+
+func (enc *msgpackEncoder) str(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		enc.byte1(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		enc.buf = append(enc.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		enc.uint16(0xda, uint16(n))
+	default:
+		enc.uint32(0xdb, uint32(n))
+	}
+	enc.buf = append(enc.buf, s...)
+}
+
+func (enc *msgpackEncoder) bin(bs []byte) {
+	n := len(bs)
+	switch {
+	case n <= math.MaxUint8:
+		enc.buf = append(enc.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		enc.uint16(0xc5, uint16(n))
+	default:
+		enc.uint32(0xc6, uint32(n))
+	}
+	enc.buf = append(enc.buf, bs...)
+}
+
+// time encode as the timestamp 96 extension(type -1)
+func (enc *msgpackEncoder) time(t time.Time) {
+	enc.buf = append(enc.buf, 0xc7, 12, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	data := enc.buf[len(enc.buf)-12:]
+	binary.BigEndian.PutUint32(data, uint32(t.Nanosecond()))
+	binary.BigEndian.PutUint64(data[4:], uint64(t.Unix()))
+}
+
+func (enc *msgpackEncoder) arrayLen(n int) {
+	switch {
+	case n <= 15:
+		enc.byte1(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		enc.uint16(0xdc, uint16(n))
+	default:
+		enc.uint32(0xdd, uint32(n))
+	}
+}
+
+func (enc *msgpackEncoder) mapStr(m map[string]interface{}) error {
+	n := len(m)
+	switch {
+	case n <= 15:
+		enc.byte1(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		enc.uint16(0xde, uint16(n))
+	default:
+		enc.uint32(0xdf, uint32(n))
+	}
+
+	// sort the keys, the same event always has the same bytes.
+	keys := make([]string, 0, n)
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		enc.str(key)
+		if err := enc.encode(m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackDecoder a minimal MessagePack decoder
+type msgpackDecoder struct {
+	buf []byte
+	pos int
+}
+
+// next read n bytes
+func (dec *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || dec.pos+n > len(dec.buf) {
+		return nil, errMsgpackShort
+	}
+
+	bs := dec.buf[dec.pos : dec.pos+n]
+	dec.pos += n
+	return bs, nil
+}
+
+// size read a big endian unsigned size of n bytes
+func (dec *msgpackDecoder) size(n int) (int, error) {
+	bs, err := dec.next(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 1:
+		return int(bs[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(bs)), nil
+	default:
+		return int(binary.BigEndian.Uint32(bs)), nil
+	}
+}
+
+func (dec *msgpackDecoder) decode() (interface{}, error) {
+	bs, err := dec.next(1)
+	if err != nil {
+		return nil, err
+	}
+
+	code := bs[0]
+	switch {
+	case code <= 0x7f: // positive fixint
+		return int64(code), nil
+	case code >= 0xe0: // negative fixint
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80: // fixmap
+		return dec.mapStr(int(code & 0x0f))
+	case code&0xf0 == 0x90: // fixarray
+		return dec.array(int(code & 0x0f))
+	case code&0xe0 == 0xa0: // fixstr
+		return dec.str(int(code & 0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin 8/16/32
+		n, err := dec.size(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+
+		bs, err := dec.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), bs...), nil
+	case 0xc7, 0xc8, 0xc9: // ext 8/16/32
+		n, err := dec.size(1 << (code - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return dec.ext(n)
+	case 0xca:
+		bs, err := dec.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(bs)), nil
+	case 0xcb:
+		bs, err := dec.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(bs)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8/16/32/64
+		bs, err := dec.next(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+
+		n := beUint(bs)
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8/16/32/64
+		bs, err := dec.next(1 << (code - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+
+		switch len(bs) {
+		case 1:
+			return int64(int8(bs[0])), nil
+		case 2:
+			return int64(int16(binary.BigEndian.Uint16(bs))), nil
+		case 4:
+			return int64(int32(binary.BigEndian.Uint32(bs))), nil
+		default:
+			return int64(binary.BigEndian.Uint64(bs)), nil
+		}
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1/2/4/8/16
+		return dec.ext(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb: // str 8/16/32
+		n, err := dec.size(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return dec.str(n)
+	case 0xdc, 0xdd: // array 16/32
+		n, err := dec.size(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return dec.array(n)
+	case 0xde, 0xdf: // map 16/32
+		n, err := dec.size(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return dec.mapStr(n)
+	}
+	return nil, fmt.Errorf("event: msgpack: unsupported code 0x%x", code)
+}
+
+func (dec *msgpackDecoder) str(n int) (string, error) {
+	bs, err := dec.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func (dec *msgpackDecoder) array(n int) ([]interface{}, error) {
+	if n > len(dec.buf)-dec.pos {
+		return nil, errMsgpackShort
+	}
+
+	arr := make([]interface{}, n)
+	for i := range arr {
+		val, err := dec.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = val
+	}
+	return arr, nil
+}
+
+func (dec *msgpackDecoder) mapStr(n int) (M, error) {
+	if n > len(dec.buf)-dec.pos {
+		return nil, errMsgpackShort
+	}
+
+	m := make(M, n)
+	for i := 0; i < n; i++ {
+		key, err := dec.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		ks, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("event: msgpack: the map key must be string, got %T", key)
+		}
+
+		if m[ks], err = dec.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ext decode the extension, only support the timestamp(type -1)
+func (dec *msgpackDecoder) ext(n int) (interface{}, error) {
+	bs, err := dec.next(n + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, data := int8(bs[0]), bs[1:]
+	if typ != -1 {
+		return nil, fmt.Errorf("event: msgpack: unsupported extension type %d", typ)
+	}
+
+	switch len(data) {
+	case 4: // timestamp 32
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8: // timestamp 64
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+	case 12: // timestamp 96
+		nsec := binary.BigEndian.Uint32(data)
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(nsec)), nil
+	}
+	return nil, fmt.Errorf("event: msgpack: invalid timestamp length %d", len(data))
+}
+
+// beUint read a big endian unsigned integer
+func beUint(bs []byte) uint64 {
+	var n uint64
+	for _, b := range bs {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
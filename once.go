@@ -0,0 +1,84 @@
+package event
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// timesListener the listener will be removed after called n times
+type timesListener struct {
+	em   *Manager
+	name string
+	li   *ListenerItem
+	// remain call times
+	remain   int64
+	listener IListener
+}
+
+// Handle event. implements the IListener interface
+func (l *timesListener) Handle(e IEvent) error {
+	return l.HandleContext(context.Background(), e)
+}
+
+// HandleContext event. implements the IContextListener interface
+func (l *timesListener) HandleContext(ctx context.Context, e IEvent) error {
+	for {
+		n := atomic.LoadInt64(&l.remain)
+		if n <= 0 {
+			// it has been fired n times, maybe in a concurrent publish.
+			return nil
+		}
+
+		if atomic.CompareAndSwapInt64(&l.remain, n, n-1) {
+			if n == 1 {
+				l.em.removeListenerByID(l.name, l.li.ID)
+				// the removed listener may hold a pending event, flush it on Close.
+				if _, ok := l.listener.(Flusher); ok {
+					l.em.removedFlushers.Store(l.li.ID, l)
+				}
+			}
+			break
+		}
+	}
+
+	if cl, ok := l.listener.(IContextListener); ok {
+		return cl.HandleContext(ctx, e)
+	}
+	return l.listener.Handle(e)
+}
+
+// Flush the pending event of the wrapped listener. implements the Flusher
+func (l *timesListener) Flush() error {
+	if f, ok := l.listener.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// ListenOnce register a listener, it will be removed after the first call.
+func (em *Manager) ListenOnce(name string, listener IListener, priority ...int) *Subscription {
+	return em.ListenTimes(name, 1, listener, priority...)
+}
+
+// ListenTimes register a listener, it will be removed after called n times.
+// it is safe on concurrent publish, the listener is called at most n times.
+func (em *Manager) ListenTimes(name string, n int, listener IListener, priority ...int) *Subscription {
+	if n < 1 {
+		panic("event: the listen times must be greater than 0")
+	}
+	if listener == nil {
+		panic("event: the event '" + name + "' listener cannot be empty")
+	}
+
+	pv := Normal
+	if len(priority) > 0 {
+		pv = priority[0]
+	}
+
+	name = checkPattern(name)
+	tl := &timesListener{em: em, name: name, remain: int64(n), listener: listener}
+	tl.li = &ListenerItem{Priority: pv, Listener: tl}
+
+	// the li.ID is set before the listener is visible to publish.
+	return em.addListenerItem(name, tl.li)
+}
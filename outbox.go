@@ -0,0 +1,479 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTxDone the Tx is already committed or rolled back
+	ErrTxDone = errors.New("event: the transaction is already committed or rolled back")
+	// ErrOutboxCorrupt the outbox file is corrupt
+	ErrOutboxCorrupt = errors.New("event: the outbox is corrupt")
+)
+
+// OutboxEntry an event in the outbox
+type OutboxEntry struct {
+	// ID the entry ID, it is the event ID
+	ID string
+	// Event the event to deliver
+	Event IEvent
+	// Time the saved time
+	Time time.Time
+}
+
+// OutboxStore persist the events of the committing Tx. the events are marked
+// delivered after published, so the pending events can be redelivered after a crash.
+// the implementations must be safe for concurrent use.
+type OutboxStore interface {
+	// Save the entries atomically
+	Save(entries []*OutboxEntry) error
+	// Pending get the not delivered entries, in the saved order
+	Pending() ([]*OutboxEntry, error)
+	// MarkDelivered mark the entry is delivered
+	MarkDelivered(id string) error
+}
+
+// Tx is a unit of work, the events published in it are delivered in order
+// on Commit, and discarded on Rollback. it is safe for concurrent use.
+//
+// Usage:
+// 	tx := em.Begin()
+// 	tx.Publish("order.created", event.M{"id": 1})
+// 	if err := saveOrder(); err != nil {
+// 		tx.Rollback()
+// 		return err
+// 	}
+// 	return tx.Commit(ctx)
+type Tx struct {
+	em     *Manager
+	mu     sync.Mutex
+	events []IEvent
+	done   bool
+}
+
+// Begin a Tx
+func (em *Manager) Begin() *Tx {
+	return &Tx{em: em}
+}
+
+// Transaction run the fn in a Tx. commit on the fn return nil, else rollback.
+func (em *Manager) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	tx := em.Begin()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Publish queue an event by name, it is delivered on Commit.
+func (tx *Tx) Publish(name string, params M) (error, IEvent) {
+	name = checkName(name)
+	e := tx.em.copyBasicEvent(name, params)
+	return tx.Add(e), e
+}
+
+// Add queue an event, it is delivered on Commit.
+func (tx *Tx) Add(e IEvent) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+
+	// stamp the ID for the outbox entry, and the time is the publish time.
+	tx.em.stampEnvelope(context.Background(), e)
+	tx.events = append(tx.events, e)
+	return nil
+}
+
+// Len get the number of queued events
+func (tx *Tx) Len() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return len(tx.events)
+}
+
+// Rollback discard the queued events
+func (tx *Tx) Rollback() {
+	tx.mu.Lock()
+	tx.done = true
+	tx.events = nil
+	tx.mu.Unlock()
+}
+
+// Commit deliver the queued events in order.
+//
+// If the Options.Outbox is set, the events are saved to the outbox before
+// delivering, and marked delivered one by one. on a publish error, the
+// remaining events are kept in the outbox, see RelayOutbox.
+// the delivery stop on the first error, and the error is returned.
+func (tx *Tx) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	if tx.done {
+		tx.mu.Unlock()
+		return ErrTxDone
+	}
+
+	tx.done = true
+	events := tx.events
+	tx.events = nil
+	tx.mu.Unlock()
+
+	em := tx.em
+	if em.Outbox == nil {
+		for _, e := range events {
+			if err := em.publishContext(ctx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	now := em.Clock.Now()
+	entries := make([]*OutboxEntry, len(events))
+	for i, e := range events {
+		entries[i] = &OutboxEntry{ID: eventID(e), Event: e, Time: now}
+		// the RelayOutbox will skip the in-flight entries
+		em.outboxInflight.Store(entries[i].ID, true)
+	}
+	defer func() {
+		for _, en := range entries {
+			em.outboxInflight.Delete(en.ID)
+		}
+	}()
+
+	if err := em.Outbox.Save(entries); err != nil {
+		return err
+	}
+	return em.deliverOutbox(ctx, entries)
+}
+
+// RelayOutbox redeliver the pending events of the Options.Outbox in order,
+// return the number of delivered events. call it on start up, or periodically.
+// the delivery is at-least-once, an event may be delivered again after a crash.
+//
+// The entries being delivered by a Commit or another RelayOutbox of the manager
+// are skipped. do not share the outbox store between the managers or processes
+// that relay concurrently, else the events will be delivered twice.
+func (em *Manager) RelayOutbox(ctx context.Context) (int, error) {
+	if em.Outbox == nil {
+		return 0, nil
+	}
+
+	entries, err := em.Outbox.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for i, en := range entries {
+		if _, loaded := em.outboxInflight.LoadOrStore(en.ID, true); loaded {
+			continue
+		}
+
+		err = em.deliverOutbox(ctx, entries[i:i+1])
+		em.outboxInflight.Delete(en.ID)
+		if err != nil {
+			return n, fmt.Errorf("event: relay the outbox entry '%s': %w", en.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// deliverOutbox publish the entries and mark delivered, stop on the first error.
+func (em *Manager) deliverOutbox(ctx context.Context, entries []*OutboxEntry) error {
+	for _, en := range entries {
+		if err := em.publishContext(ctx, en.Event); err != nil {
+			return err
+		}
+		if err := em.Outbox.MarkDelivered(en.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventID get the envelope ID of the event, generate one if not exists.
+func eventID(e IEvent) string {
+	if env, ok := e.(IEnvelope); ok && env.ID() != "" {
+		return env.ID()
+	}
+	return NewID()
+}
+
+// MemoryOutbox an in-memory outbox store, the pending events are lost on exit.
+type MemoryOutbox struct {
+	mu      sync.Mutex
+	entries []*OutboxEntry
+}
+
+// NewMemoryOutbox create an in-memory outbox store
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{}
+}
+
+// Save the entries
+func (o *MemoryOutbox) Save(entries []*OutboxEntry) error {
+	o.mu.Lock()
+	o.entries = append(o.entries, entries...)
+	o.mu.Unlock()
+	return nil
+}
+
+// Pending get the not delivered entries
+func (o *MemoryOutbox) Pending() ([]*OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*OutboxEntry(nil), o.entries...), nil
+}
+
+// MarkDelivered remove the delivered entry
+func (o *MemoryOutbox) MarkDelivered(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, en := range o.entries {
+		if en.ID == id {
+			o.entries = append(o.entries[:i:i], o.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// compactOutboxDone the FileOutbox is compacted when the number of done lines reach it
+const compactOutboxDone = 1024
+
+// FileOutbox a file-backed outbox store. the operations are appended to the
+// file as JSON lines and synced. the file is truncated on all entries delivered,
+// and compacted to the pending entries on too many delivered entries.
+type FileOutbox struct {
+	mu    sync.Mutex
+	path  string
+	codec EventCodec
+	// the pending entries, in the saved order
+	pending []*OutboxEntry
+	// the number of done lines in the file
+	done int
+}
+
+// fileOutboxLine the JSON line format of the FileOutbox
+type fileOutboxLine struct {
+	// Op is "add" or "done"
+	Op    string    `json:"op"`
+	ID    string    `json:"id"`
+	Event []byte    `json:"event,omitempty"`
+	Time  time.Time `json:"time,omitempty"`
+}
+
+// NewFileOutbox create a file-backed outbox store, and load the pending entries.
+// the codec encode the events, default is the JSONCodec.
+func NewFileOutbox(path string, codec EventCodec) (*FileOutbox, error) {
+	if codec == nil {
+		codec = &JSONCodec{}
+	}
+
+	o := &FileOutbox{path: path, codec: codec}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// load the pending entries from the file. an incomplete line at the end is
+// left by a crash on write, it will be truncated. other bad lines are corrupt.
+func (o *FileOutbox) load() error {
+	bs, err := os.ReadFile(o.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	// truncate the incomplete line, else the next write will be appended to it.
+	end := bytes.LastIndexByte(bs, '\n') + 1
+	if end < len(bs) {
+		if err = os.Truncate(o.path, int64(end)); err != nil {
+			return err
+		}
+	}
+
+	delivered := make(map[string]bool)
+	for i, raw := range bytes.Split(bs[:end], []byte{'\n'}) {
+		if len(raw) == 0 {
+			continue
+		}
+
+		var line fileOutboxLine
+		if err = json.Unmarshal(raw, &line); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrOutboxCorrupt, i+1, err)
+		}
+
+		switch line.Op {
+		case "add":
+			e, err := o.codec.Decode(line.Event)
+			if err != nil {
+				return fmt.Errorf("%w: decode the entry '%s': %v", ErrOutboxCorrupt, line.ID, err)
+			}
+			o.pending = append(o.pending, &OutboxEntry{ID: line.ID, Event: e, Time: line.Time})
+		case "done":
+			delivered[line.ID] = true
+			o.done++
+		default:
+			return fmt.Errorf("%w: line %d: unknown op %q", ErrOutboxCorrupt, i+1, line.Op)
+		}
+	}
+
+	if len(delivered) > 0 {
+		pending := o.pending[:0]
+		for _, en := range o.pending {
+			if !delivered[en.ID] {
+				pending = append(pending, en)
+			}
+		}
+		o.pending = pending
+	}
+	return nil
+}
+
+// remove a pending entry by ID
+func (o *FileOutbox) remove(id string) bool {
+	for i, en := range o.pending {
+		if en.ID == id {
+			o.pending = append(o.pending[:i:i], o.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// encode the lines as JSON lines
+func encodeOutboxLines(lines []*fileOutboxLine) ([]byte, error) {
+	var buf []byte
+	for _, line := range lines {
+		bs, err := json.Marshal(line)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, bs...), '\n')
+	}
+	return buf, nil
+}
+
+// addLine create the add line of the entry
+func (o *FileOutbox) addLine(en *OutboxEntry) (*fileOutboxLine, error) {
+	bs, err := o.codec.Encode(en.Event)
+	if err != nil {
+		return nil, err
+	}
+	return &fileOutboxLine{Op: "add", ID: en.ID, Event: bs, Time: en.Time}, nil
+}
+
+// write the lines to the file and sync
+func (o *FileOutbox) write(lines ...*fileOutboxLine) error {
+	buf, err := encodeOutboxLines(lines)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Save the entries, all lines are written and synced once.
+func (o *FileOutbox) Save(entries []*OutboxEntry) error {
+	lines := make([]*fileOutboxLine, len(entries))
+	for i, en := range entries {
+		line, err := o.addLine(en)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.write(lines...); err != nil {
+		return err
+	}
+
+	o.pending = append(o.pending, entries...)
+	return nil
+}
+
+// Pending get the not delivered entries
+func (o *FileOutbox) Pending() ([]*OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*OutboxEntry(nil), o.pending...), nil
+}
+
+// MarkDelivered mark the entry is delivered
+func (o *FileOutbox) MarkDelivered(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.remove(id) {
+		return nil
+	}
+
+	// all delivered, compact the file
+	if len(o.pending) == 0 {
+		o.done = 0
+		return os.Truncate(o.path, 0)
+	}
+
+	if o.done+1 >= compactOutboxDone {
+		return o.compact()
+	}
+
+	if err := o.write(&fileOutboxLine{Op: "done", ID: id}); err != nil {
+		return err
+	}
+	o.done++
+	return nil
+}
+
+// compact rewrite the file with the pending entries only, by a temp file.
+func (o *FileOutbox) compact() error {
+	lines := make([]*fileOutboxLine, len(o.pending))
+	for i, en := range o.pending {
+		line, err := o.addLine(en)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+	}
+
+	buf, err := encodeOutboxLines(lines)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(o.path, buf); err != nil {
+		return err
+	}
+
+	o.done = 0
+	return nil
+}
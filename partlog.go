@@ -0,0 +1,572 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNotAssigned the partition is not assigned to the consumer, it is rebalanced.
+	ErrNotAssigned = errors.New("event: the partition is not assigned to the consumer")
+	// ErrConsumerClosed the consumer is left the group
+	ErrConsumerClosed = errors.New("event: the consumer is closed")
+)
+
+// LogRecord an event record of the partitioned log
+type LogRecord struct {
+	// Partition the partition index
+	Partition int
+	// Offset the offset in the partition, start from 0
+	Offset int64
+	// Key the partition key
+	Key string
+	// Event the recorded event
+	Event IEvent
+}
+
+// PartitionedLog an in-process, offset-addressed event log. the events are
+// partitioned by a key in the event data, the events with the same key are
+// in the same partition and keep the order.
+//
+// The records are kept in memory, and the log grows without limit until
+// truncated, see Truncate and TruncateCommitted. the consumer groups track the
+// committed offsets, and deliver the records at-least-once.
+//
+// Usage:
+// 	log := event.NewPartitionedLog(4, "user_id")
+// 	log.Attach(em, "order.*")
+//
+// 	c := log.Group("mailer").Join("worker-1")
+// 	defer c.Leave()
+// 	err := c.Run(ctx, event.ListenerFunc(func(e event.IEvent) error {
+// 		return nil
+// 	}))
+type PartitionedLog struct {
+	mu         sync.RWMutex
+	keyField   string
+	partitions [][]*LogRecord
+	// the offset of the first record of each partition, it is moved by Truncate
+	bases  []int64
+	groups map[string]*ConsumerGroup
+	// round-robin partition for the events without key
+	next int
+	// closed and replaced on the records appended or groups rebalanced
+	notify chan struct{}
+}
+
+// NewPartitionedLog create a partitioned log. the keyField is the data key of
+// the partition key, the events without the key are partitioned by round-robin.
+func NewPartitionedLog(partitions int, keyField string) *PartitionedLog {
+	if partitions <= 0 {
+		panic("event: the number of partitions must be greater than 0")
+	}
+
+	return &PartitionedLog{
+		keyField:   keyField,
+		partitions: make([][]*LogRecord, partitions),
+		bases:      make([]int64, partitions),
+		groups:     make(map[string]*ConsumerGroup),
+		notify:     make(chan struct{}),
+	}
+}
+
+// Partitions get the number of partitions
+func (l *PartitionedLog) Partitions() int {
+	return len(l.partitions)
+}
+
+// PartitionFor get the partition index of the key
+func (l *PartitionedLog) PartitionFor(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(l.partitions)))
+}
+
+// Append an event to the log
+func (l *PartitionedLog) Append(e IEvent) *LogRecord {
+	var key string
+	if val := e.Get(l.keyField); val != nil {
+		key = fmt.Sprint(val)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var p int
+	if key == "" {
+		p = l.next
+		l.next = (l.next + 1) % len(l.partitions)
+	} else {
+		p = l.PartitionFor(key)
+	}
+
+	r := &LogRecord{Partition: p, Offset: l.end(p), Key: key, Event: e}
+	l.partitions[p] = append(l.partitions[p], r)
+	l.broadcast()
+	return r
+}
+
+// Attach the log to the manager as a publish hook. the published events
+// match the pattern will be appended, the pattern same as Listen.
+// the replayed events are skipped, they are already in the log.
+// call the returned func to detach.
+func (l *PartitionedLog) Attach(em *Manager, pattern string) func() {
+	pattern = checkPattern(pattern)
+	return em.OnPublish(func(ctx context.Context, e IEvent) error {
+		if IsReplay(ctx) {
+			return nil
+		}
+		if pattern == e.Name() || matchName(pattern, e.Name()) {
+			l.Append(e)
+		}
+		return nil
+	})
+}
+
+// Read the records of a partition from the offset, max <= 0 is not limited.
+// the truncated records are skipped, read from the start offset.
+func (l *PartitionedLog) Read(partition int, offset int64, max int) []*LogRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.read(partition, offset, max)
+}
+
+// read the records, must hold the lock
+func (l *PartitionedLog) read(partition int, offset int64, max int) []*LogRecord {
+	if partition < 0 || partition >= len(l.partitions) {
+		return nil
+	}
+
+	base := l.bases[partition]
+	if offset < base {
+		offset = base
+	}
+
+	rs := l.partitions[partition]
+	if offset >= base+int64(len(rs)) {
+		return nil
+	}
+
+	rs = rs[offset-base:]
+	if max > 0 && len(rs) > max {
+		rs = rs[:max]
+	}
+	return append([]*LogRecord(nil), rs...)
+}
+
+// StartOffset get the offset of the first not truncated record of the partition
+func (l *PartitionedLog) StartOffset(partition int) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.bases[partition]
+}
+
+// EndOffset get the next offset of the partition
+func (l *PartitionedLog) EndOffset(partition int) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.end(partition)
+}
+
+// end get the next offset of the partition, must hold the lock
+func (l *PartitionedLog) end(partition int) int64 {
+	return l.bases[partition] + int64(len(l.partitions[partition]))
+}
+
+// Truncate drop the records of the partition before the offset, return the
+// number of dropped records. the offsets of the remaining records are not changed,
+// the consumers behind the offset will skip the dropped records.
+func (l *PartitionedLog) Truncate(partition int, before int64) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if partition < 0 || partition >= len(l.partitions) {
+		return 0
+	}
+
+	if end := l.end(partition); before > end {
+		before = end
+	}
+
+	n := int(before - l.bases[partition])
+	if n <= 0 {
+		return 0
+	}
+
+	// copy the remaining records, so the dropped records can be collected.
+	l.partitions[partition] = append([]*LogRecord(nil), l.partitions[partition][n:]...)
+	l.bases[partition] = before
+	return n
+}
+
+// TruncateCommitted drop the records committed by all consumer groups,
+// return the number of dropped records. nothing is dropped if no groups.
+func (l *PartitionedLog) TruncateCommitted() int {
+	l.mu.RLock()
+	groups := make([]*ConsumerGroup, 0, len(l.groups))
+	for _, g := range l.groups {
+		groups = append(groups, g)
+	}
+	l.mu.RUnlock()
+
+	if len(groups) == 0 {
+		return 0
+	}
+
+	// the min committed offset of each partition
+	mins := make([]int64, len(l.partitions))
+	for i, g := range groups {
+		g.mu.Lock()
+		for p, off := range g.committed {
+			if i == 0 || off < mins[p] {
+				mins[p] = off
+			}
+		}
+		g.mu.Unlock()
+	}
+
+	var n int
+	for p, off := range mins {
+		n += l.Truncate(p, off)
+	}
+	return n
+}
+
+// broadcast wake up the waiting consumers, must hold the lock
+func (l *PartitionedLog) broadcast() {
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// Group get or create a consumer group by name
+func (l *PartitionedLog) Group(name string) *ConsumerGroup {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	g, ok := l.groups[name]
+	if !ok {
+		g = &ConsumerGroup{
+			log:       l,
+			name:      name,
+			committed: make([]int64, len(l.partitions)),
+			members:   make(map[string]*Consumer),
+		}
+		l.groups[name] = g
+	}
+	return g
+}
+
+// ConsumerGroup the consumers in a group share the partitions, each partition
+// is assigned to one member. the group track the committed offset of each partition.
+type ConsumerGroup struct {
+	log  *PartitionedLog
+	name string
+
+	mu         sync.Mutex
+	committed  []int64
+	members    map[string]*Consumer
+	generation int
+}
+
+// Name get the group name
+func (g *ConsumerGroup) Name() string {
+	return g.name
+}
+
+// Committed get the committed offset of the partition, it is the next offset to consume.
+func (g *ConsumerGroup) Committed(partition int) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.committed[partition]
+}
+
+// Lag get the total number of not committed records
+func (g *ConsumerGroup) Lag() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.log.mu.RLock()
+	defer g.log.mu.RUnlock()
+
+	var lag int64
+	for p := range g.log.partitions {
+		// the truncated records are not counted
+		from := g.committed[p]
+		if base := g.log.bases[p]; from < base {
+			from = base
+		}
+		lag += g.log.end(p) - from
+	}
+	return lag
+}
+
+// Generation get the rebalance generation, it is increased on members changed.
+func (g *ConsumerGroup) Generation() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.generation
+}
+
+// Members get the sorted member IDs
+func (g *ConsumerGroup) Members() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.memberIDs()
+}
+
+// memberIDs get the sorted member IDs, must hold the lock
+func (g *ConsumerGroup) memberIDs() []string {
+	ids := make([]string, 0, len(g.members))
+	for id := range g.members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Join the group by the member ID, the partitions are rebalanced.
+// join with an exists member ID will return the exists consumer.
+func (g *ConsumerGroup) Join(memberID string) *Consumer {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok := g.members[memberID]; ok {
+		return c
+	}
+
+	c := &Consumer{group: g, id: memberID}
+	g.members[memberID] = c
+	g.rebalance()
+	return c
+}
+
+// rebalance assign the partitions to the members by round-robin of the sorted
+// member IDs. the positions are reset to the committed offsets, so the not
+// committed records will be delivered again. must hold the lock.
+func (g *ConsumerGroup) rebalance() {
+	g.generation++
+	ids := g.memberIDs()
+	for _, c := range g.members {
+		c.positions = make(map[int]int64)
+	}
+
+	if len(ids) > 0 {
+		for p := range g.committed {
+			c := g.members[ids[p%len(ids)]]
+			c.positions[p] = g.committed[p]
+		}
+	}
+
+	// wake up the waiting consumers
+	g.log.mu.Lock()
+	g.log.broadcast()
+	g.log.mu.Unlock()
+}
+
+// Consumer a member of a consumer group
+type Consumer struct {
+	group *ConsumerGroup
+	id    string
+	// the assigned partitions and the next offset to poll. guarded by the group mu.
+	positions map[int]int64
+	closed    bool
+	// the start partition of the next poll, for fairness
+	start int
+}
+
+// ID get the member ID
+func (c *Consumer) ID() string {
+	return c.id
+}
+
+// Assignment get the sorted assigned partitions
+func (c *Consumer) Assignment() []int {
+	c.group.mu.Lock()
+	defer c.group.mu.Unlock()
+
+	ps := make([]int, 0, len(c.positions))
+	for p := range c.positions {
+		ps = append(ps, p)
+	}
+	sort.Ints(ps)
+	return ps
+}
+
+// Leave the group, the partitions are rebalanced to the other members.
+func (c *Consumer) Leave() {
+	g := c.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.positions = nil
+	delete(g.members, c.id)
+	g.rebalance()
+}
+
+// Poll the records of the assigned partitions, max <= 0 is not limited.
+// it blocks until there are records, or the ctx is done.
+func (c *Consumer) Poll(ctx context.Context, max int) ([]*LogRecord, error) {
+	rs, _, err := c.pollWait(ctx, max)
+	return rs, err
+}
+
+// pollWait poll the records, wait until there are records or the ctx is done.
+// return the group generation of the polled records.
+func (c *Consumer) pollWait(ctx context.Context, max int) ([]*LogRecord, int, error) {
+	for {
+		rs, gen, wait, err := c.poll(max)
+		if err != nil || len(rs) > 0 {
+			return rs, gen, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// poll the records without waiting, return the channel to wait on no records.
+func (c *Consumer) poll(max int) ([]*LogRecord, int, <-chan struct{}, error) {
+	g := c.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c.closed {
+		return nil, 0, nil, ErrConsumerClosed
+	}
+
+	ps := make([]int, 0, len(c.positions))
+	for p := range c.positions {
+		ps = append(ps, p)
+	}
+	sort.Ints(ps)
+
+	g.log.mu.RLock()
+	defer g.log.mu.RUnlock()
+
+	var rs []*LogRecord
+	for i := range ps {
+		p := ps[(c.start+i)%len(ps)]
+		limit := 0
+		if max > 0 {
+			if limit = max - len(rs); limit <= 0 {
+				break
+			}
+		}
+
+		got := g.log.read(p, c.positions[p], limit)
+		if len(got) > 0 {
+			c.positions[p] = got[len(got)-1].Offset + 1
+			rs = append(rs, got...)
+		}
+	}
+
+	c.start++
+	return rs, g.generation, g.log.notify, nil
+}
+
+// isCurrent check the generation is not changed by rebalance, and the consumer is not closed.
+func (c *Consumer) isCurrent(gen int) bool {
+	g := c.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !c.closed && g.generation == gen
+}
+
+// Commit the record is consumed, the committed offset of the partition is
+// moved to after the record. return ErrNotAssigned if the partition is rebalanced
+// to another member.
+func (c *Consumer) Commit(r *LogRecord) error {
+	g := c.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c.closed {
+		return ErrConsumerClosed
+	}
+	if _, ok := c.positions[r.Partition]; !ok {
+		return ErrNotAssigned
+	}
+
+	if next := r.Offset + 1; next > g.committed[r.Partition] {
+		g.committed[r.Partition] = next
+		// the position may be reset to the old committed offset by a rebalance
+		if c.positions[r.Partition] < next {
+			c.positions[r.Partition] = next
+		}
+	}
+	return nil
+}
+
+// Seek set the next offset to poll of an assigned partition
+func (c *Consumer) Seek(partition int, offset int64) error {
+	g := c.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c.closed {
+		return ErrConsumerClosed
+	}
+	if _, ok := c.positions[partition]; !ok {
+		return ErrNotAssigned
+	}
+
+	c.positions[partition] = offset
+	return nil
+}
+
+// Run poll and handle the records until the ctx is done or the handler return
+// an error. the handler can be an IContextListener. the record is committed after handled. on error, the consumer seek
+// back to the failed record, so it will be delivered again.
+//
+// On the group is rebalanced, the remaining records of the batch are dropped
+// and polled again, the revoked partitions are handled by the new owners only.
+func (c *Consumer) Run(ctx context.Context, handler IListener) error {
+	for {
+		rs, gen, err := c.pollWait(ctx, 0)
+		if err != nil {
+			return err
+		}
+
+		for i, r := range rs {
+			// rebalanced, the positions are reset to the committed offsets.
+			if !c.isCurrent(gen) {
+				break
+			}
+
+			if err = callListener(ctx, &ListenerItem{Listener: handler}, r.Event); err != nil {
+				c.seekBack(rs[i:])
+				return err
+			}
+
+			// the partition is rebalanced to another member
+			if err = c.Commit(r); err == ErrNotAssigned {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// seekBack seek each partition back to the first not handled record
+func (c *Consumer) seekBack(rs []*LogRecord) {
+	seen := make(map[int]bool)
+	for _, r := range rs {
+		if !seen[r.Partition] {
+			seen[r.Partition] = true
+			_ = c.Seek(r.Partition, r.Offset)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// There are some modes on the rate limit exceeded
+const (
+	// RateLimitReject reject the event. publish will return *RateLimitError,
+	// the listener will be skipped.
+	RateLimitReject = iota
+	// RateLimitDelay wait until the event is allowed, or the ctx is done
+	RateLimitDelay
+)
+
+// RateLimitError the event is rejected by the rate limiter
+type RateLimitError struct {
+	// EventName the rejected event name
+	EventName string
+	// Pattern the rate limit pattern. it is empty for a listener limit
+	Pattern string
+	// ListenerID the limited listener ID. it is 0 for a publish limit
+	ListenerID uint64
+}
+
+// Error string
+func (e *RateLimitError) Error() string {
+	if e.ListenerID > 0 {
+		return fmt.Sprintf("event: the event '%s' is rate limited for listener #%d", e.EventName, e.ListenerID)
+	}
+	return fmt.Sprintf("event: the event '%s' is rate limited by '%s'", e.EventName, e.Pattern)
+}
+
+// RateLimiter is a token bucket rate limiter
+type RateLimiter struct {
+	// Mode on the limit exceeded. default is RateLimitReject
+	Mode int
+
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter create a token bucket, rate is the tokens per second, burst is the bucket size.
+func NewRateLimiter(rate float64, burst int, mode ...int) *RateLimiter {
+	if rate <= 0 || burst < 1 {
+		panic("event: the rate limiter rate must be positive and the burst must be greater than 0")
+	}
+
+	l := &RateLimiter{rate: rate, burst: burst, tokens: float64(burst)}
+	if len(mode) > 0 {
+		l.Mode = mode[0]
+	}
+	return l
+}
+
+// refill the tokens by the elapsed time. must hold the lock.
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+
+	if now.After(l.last) {
+		l.last = now
+	}
+}
+
+// Allow take a token at the time. return false if no token.
+func (l *RateLimiter) Allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	return false
+}
+
+// Reserve take a token at the time, and return the wait time until the token is available.
+func (l *RateLimiter) Reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait take a token by the limiter mode. return false if it is rejected.
+func (l *RateLimiter) wait(ctx context.Context, clock Clock) (bool, error) {
+	if l.Mode != RateLimitDelay {
+		return l.Allow(clock.Now()), nil
+	}
+
+	d := l.Reserve(clock.Now())
+	if d <= 0 {
+		return true, nil
+	}
+
+	select {
+	case <-clock.After(d):
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// rateRule the rate limit of an event name pattern
+type rateRule struct {
+	pattern string
+	limiter *RateLimiter
+}
+
+// SetRateLimit set a token bucket rate limit for the events matched the
+// name pattern on publish. rate is the events per second, burst is the bucket size.
+// mode is RateLimitReject or RateLimitDelay. set again will replace the old one.
+//
+// Usage:
+// 	em.SetRateLimit("user.login", 100, 10, event.RateLimitReject)
+// 	em.SetRateLimit("order.**", 1000, 100, event.RateLimitDelay)
+func (em *Manager) SetRateLimit(pattern string, rate float64, burst, mode int) {
+	pattern = checkPattern(pattern)
+	rule := &rateRule{pattern: pattern, limiter: NewRateLimiter(rate, burst, mode)}
+
+	em.update(func(r *registry) {
+		r.removeRateRule(pattern)
+		r.rateRules = append(r.rateRules, rule)
+	})
+}
+
+// RemoveRateLimit remove the rate limit of the pattern
+func (em *Manager) RemoveRateLimit(pattern string) {
+	em.update(func(r *registry) {
+		r.removeRateRule(pattern)
+	})
+}
+
+// checkRateLimit check the publish rate limits of the event name
+func (em *Manager) checkRateLimit(ctx context.Context, r *registry, name string) error {
+	for _, rule := range r.rateRules {
+		if !matchName(rule.pattern, name) {
+			continue
+		}
+
+		ok, err := rule.limiter.wait(ctx, em.Clock)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			em.stats.addRateLimited(1)
+			return &RateLimitError{EventName: name, Pattern: rule.pattern}
+		}
+	}
+	return nil
+}
+
+// checkListenerLimit check the rate limit of the listener.
+// return false if the listener should be skipped.
+func (em *Manager) checkListenerLimit(ctx context.Context, li *ListenerItem, e IEvent) (bool, error) {
+	if li.RateLimit == nil {
+		return true, nil
+	}
+
+	ok, err := li.RateLimit.wait(ctx, em.Clock)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		em.stats.addListenerRateLimited(1)
+		em.handleError(e, &RateLimitError{EventName: e.Name(), ListenerID: li.ID})
+	}
+	return ok, nil
+}
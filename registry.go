@@ -0,0 +1,233 @@
+package event
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCacheSize the max number of cached event names in a registry.
+// when it is reached, the cache is cleared and filled again by the next publishes.
+const maxCacheSize = 4096
+
+// registry is an immutable snapshot of the manager's events and listeners.
+// the manager never modify a stored registry, it will clone and replace it.
+type registry struct {
+	// name of the manager, it is cleared on Reset
+	name string
+	// storage user custom IEvent instance. you can pre-define some IEvent instances.
+	events map[string]IEvent
+	// key is the event name, value is the sorted queue of listener func
+	listeners map[string]*ListenerQueue
+	// storage all event names by listened
+	listenedNames map[string]int
+	// route trie of the listened patterns, not contains the Wildcard.
+	trie *topicTrie
+	// rate limit rules on publish
+	rateRules []*rateRule
+	// hooks on publish
+	hooks []*publishHook
+
+	// cache the matched listeners by event name.
+	// it is dropped with the registry on listeners changed.
+	cache *matchCache
+	// cache the matched listeners, sorted by priority globally.
+	unifiedCache *matchCache
+}
+
+func newRegistry() *registry {
+	r := &registry{
+		events:        make(map[string]IEvent),
+		listeners:     make(map[string]*ListenerQueue),
+		listenedNames: make(map[string]int),
+	}
+
+	r.build()
+	return r
+}
+
+// clone the registry maps. the queues are shared, because they are never
+// modified after stored. the queues returned to users are copies.
+func (r *registry) clone() *registry {
+	nr := &registry{
+		name:          r.name,
+		events:        make(map[string]IEvent, len(r.events)),
+		listeners:     make(map[string]*ListenerQueue, len(r.listeners)),
+		listenedNames: make(map[string]int, len(r.listenedNames)),
+	}
+
+	for name, e := range r.events {
+		nr.events[name] = e
+	}
+	for name, lq := range r.listeners {
+		nr.listeners[name] = lq
+	}
+	for name, v := range r.listenedNames {
+		nr.listenedNames[name] = v
+	}
+
+	nr.rateRules = append([]*rateRule(nil), r.rateRules...)
+	nr.hooks = append([]*publishHook(nil), r.hooks...)
+	return nr
+}
+
+// build the route trie and reset the match cache. call it before store the registry.
+func (r *registry) build() {
+	r.trie = &topicTrie{}
+	r.cache = &matchCache{}
+	r.unifiedCache = &matchCache{}
+
+	for name := range r.listeners {
+		if name != Wildcard && isPattern(name) {
+			r.trie.insert(name)
+		}
+	}
+}
+
+// addItem add a listener item to the queue of name, the new queue is sorted.
+func (r *registry) addItem(name string, li *ListenerItem) {
+	if lq, ok := r.listeners[name]; ok {
+		r.listeners[name] = lq.withItem(li)
+	} else { // first add.
+		r.listenedNames[name] = 1
+		r.listeners[name] = (&ListenerQueue{}).Push(li)
+	}
+}
+
+// removeItem remove a listener item by ID. return false if not found.
+func (r *registry) removeItem(name string, id uint64) bool {
+	lq, ok := r.listeners[name]
+	if !ok {
+		return false
+	}
+
+	nq := lq.withoutID(id)
+	if nq == lq {
+		return false
+	}
+
+	if nq.IsEmpty() {
+		r.removeName(name)
+	} else {
+		r.listeners[name] = nq
+	}
+	return true
+}
+
+// removeName remove all listeners of the name
+func (r *registry) removeName(name string) {
+	delete(r.listeners, name)
+	delete(r.listenedNames, name)
+}
+
+// removeRateRule remove the rate limit rule of the pattern
+func (r *registry) removeRateRule(pattern string) {
+	for i, rule := range r.rateRules {
+		if rule.pattern == pattern {
+			r.rateRules = append(r.rateRules[:i:i], r.rateRules[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasListeners has listeners for the event name.
+func (r *registry) hasListeners(name string) bool {
+	_, ok := r.listenedNames[name]
+	return ok
+}
+
+// match get the ordered listeners for the event name, the result is cached.
+//
+// Order: exact name listeners, pattern listeners(sorted by pattern), '*' listeners.
+// if unified is true, all listeners are sorted by priority, and keep the add order
+// for the same priority.
+func (r *registry) match(name string, unified bool) []*ListenerItem {
+	cache := r.cache
+	if unified {
+		cache = r.unifiedCache
+	}
+
+	if items, ok := cache.load(name); ok {
+		return items
+	}
+
+	items := r.collect(name)
+	if unified && len(items) > 1 {
+		sort.Sort(byPriorityAndID(items))
+	}
+
+	cache.store(name, items)
+	return items
+}
+
+// matchCache a concurrent cache of the matched listeners, read without lock.
+// it is cleared on the number of names reach the maxCacheSize, so the
+// names that published frequently are cached again soon.
+type matchCache struct {
+	m sync.Map
+	// the approximate number of cached names
+	size int32
+}
+
+// load the cached listeners of the name
+func (c *matchCache) load(name string) ([]*ListenerItem, bool) {
+	if v, ok := c.m.Load(name); ok {
+		return v.([]*ListenerItem), true
+	}
+	return nil, false
+}
+
+// store the listeners of the name, clear the cache if it is full.
+func (c *matchCache) store(name string, items []*ListenerItem) {
+	if _, loaded := c.m.LoadOrStore(name, items); loaded {
+		return
+	}
+
+	if atomic.AddInt32(&c.size, 1) > maxCacheSize {
+		atomic.StoreInt32(&c.size, 0)
+		c.m.Range(func(key, _ interface{}) bool {
+			c.m.Delete(key)
+			return true
+		})
+	}
+}
+
+// collect the listeners for the event name
+func (r *registry) collect(name string) (items []*ListenerItem) {
+	if lq, ok := r.listeners[name]; ok && name != Wildcard {
+		items = append(items, lq.Items()...)
+	}
+
+	patterns := r.trie.match(name, nil)
+	sort.Strings(patterns)
+	for i, pattern := range patterns {
+		// skip duplicates and the exact name
+		if (i > 0 && patterns[i-1] == pattern) || pattern == name {
+			continue
+		}
+		items = append(items, r.listeners[pattern].Items()...)
+	}
+
+	if lq, ok := r.listeners[Wildcard]; ok {
+		items = append(items, lq.Items()...)
+	}
+	return
+}
+
+// byPriorityAndID sort the listener items by priority, then by the ID(add order)
+type byPriorityAndID []*ListenerItem
+
+func (ls byPriorityAndID) Len() int {
+	return len(ls)
+}
+
+func (ls byPriorityAndID) Less(i, j int) bool {
+	if ls[i].Priority == ls[j].Priority {
+		return ls[i].ID < ls[j].ID
+	}
+	return ls[i].Priority > ls[j].Priority
+}
+
+func (ls byPriorityAndID) Swap(i, j int) {
+	ls[i], ls[j] = ls[j], ls[i]
+}
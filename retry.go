@@ -0,0 +1,91 @@
+package event
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy the retry policy of a listener.
+//
+// Usage:
+// 	em.ListenItem("order.paid", &event.ListenerItem{
+// 		Listener: listener,
+// 		Retry:    &event.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second},
+// 	})
+type RetryPolicy struct {
+	// MaxAttempts the max call times, contains the first call. <= 1 is no retry.
+	MaxAttempts int
+	// InitialBackoff the wait time before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff the max wait time. 0 is no limit
+	MaxBackoff time.Duration
+	// Multiplier the backoff multiplier of each retry. default is 2
+	Multiplier float64
+	// Jitter randomize the backoff by the factor, range is 0 - 1.
+	// eg: 0.2 the backoff will be in the range [0.8*backoff, 1.2*backoff]
+	Jitter float64
+	// Retryable check the error can be retried. nil is all errors can be retried.
+	Retryable func(err error) bool
+}
+
+// Backoff get the wait time before the next retry, attempt is the failed attempt number(start from 1).
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// shouldRetry check the error should be retried after the attempt
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// invokeWithRetry invoke the listener, and retry on error by the ListenerItem.Retry policy.
+// the panic and the context done will not be retried. attempts is the call times.
+func (em *Manager) invokeWithRetry(ctx context.Context, li *ListenerItem, e IEvent) (err error, recovered bool, attempts int) {
+	err, recovered = em.invoke(ctx, li, e)
+	attempts = 1
+	if li.Retry == nil {
+		return
+	}
+
+	for attempt := 1; err != nil && !recovered && ctx.Err() == nil; attempt++ {
+		if !li.Retry.shouldRetry(attempt, err) {
+			if attempt > 1 {
+				em.stats.addRetryFailures(1)
+			}
+			return
+		}
+
+		if em.OnRetry != nil {
+			em.OnRetry(e, li, attempt, err)
+		}
+
+		select {
+		case <-em.Clock.After(li.Retry.Backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err(), false, attempts
+		}
+
+		em.stats.addRetries(1)
+		err, recovered = em.invoke(ctx, li, e)
+		attempts++
+	}
+	return
+}
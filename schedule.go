@@ -0,0 +1,207 @@
+package event
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Schedule is the handle of a delayed event
+type Schedule struct {
+	sd   *scheduler
+	at   time.Time
+	name string
+	// run on the schedule time
+	run func()
+	// index in the heap, -1 on it is removed
+	index int
+	// seq keep the add order for the same time
+	seq uint64
+}
+
+// Name get the event name
+func (s *Schedule) Name() string {
+	return s.name
+}
+
+// Time get the schedule time
+func (s *Schedule) Time() time.Time {
+	return s.at
+}
+
+// Cancel the schedule. return false if it has been fired or canceled.
+func (s *Schedule) Cancel() bool {
+	return s.sd.remove(s)
+}
+
+// scheduleHeap is a min-heap of schedules by time. implements the heap.Interface
+type scheduleHeap []*Schedule
+
+func (h scheduleHeap) Len() int {
+	return len(h)
+}
+
+func (h scheduleHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h scheduleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+	s := x.(*Schedule)
+	s.index = len(*h)
+	*h = append(*h, s)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	s := old[n-1]
+	old[n-1] = nil
+	s.index = -1
+	*h = old[:n-1]
+	return s
+}
+
+// scheduler run the schedules by a timer heap, only one clock timer is
+// pending for the earliest schedule.
+type scheduler struct {
+	clock Clock
+
+	mu     sync.Mutex
+	items  scheduleHeap
+	timer  Timer
+	seq    uint64
+	closed bool
+}
+
+func newScheduler(clock Clock) *scheduler {
+	return &scheduler{clock: clock}
+}
+
+// add a schedule to the heap
+func (sd *scheduler) add(s *Schedule) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	s.sd = sd
+	s.index = -1
+	if sd.closed {
+		return
+	}
+
+	sd.seq++
+	s.seq = sd.seq
+	heap.Push(&sd.items, s)
+
+	// it is the earliest schedule
+	if s.index == 0 {
+		sd.resetTimer()
+	}
+}
+
+// remove a schedule from the heap
+func (sd *scheduler) remove(s *Schedule) bool {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if s.index < 0 {
+		return false
+	}
+
+	isFirst := s.index == 0
+	heap.Remove(&sd.items, s.index)
+	if isFirst {
+		sd.resetTimer()
+	}
+	return true
+}
+
+// resetTimer reset the clock timer for the earliest schedule. must hold the lock.
+func (sd *scheduler) resetTimer() {
+	if sd.timer != nil {
+		sd.timer.Stop()
+		sd.timer = nil
+	}
+
+	if len(sd.items) > 0 {
+		sd.timer = sd.clock.AfterFunc(sd.items[0].at.Sub(sd.clock.Now()), sd.fire)
+	}
+}
+
+// fire run all expired schedules in time order
+func (sd *scheduler) fire() {
+	sd.mu.Lock()
+	now := sd.clock.Now()
+
+	var due []*Schedule
+	for len(sd.items) > 0 && !sd.items[0].at.After(now) {
+		due = append(due, heap.Pop(&sd.items).(*Schedule))
+	}
+
+	if !sd.closed {
+		sd.resetTimer()
+	}
+	sd.mu.Unlock()
+
+	for _, s := range due {
+		s.run()
+	}
+}
+
+// stop the scheduler, the pending schedules are discarded.
+func (sd *scheduler) stop() {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.closed = true
+	if sd.timer != nil {
+		sd.timer.Stop()
+		sd.timer = nil
+	}
+
+	for _, s := range sd.items {
+		s.index = -1
+	}
+	sd.items = nil
+}
+
+// PublishAfter publish the event by name after the delay.
+// the listener errors will be reported to the ErrorHandler.
+//
+// NOTE: after the manager is closed, the schedule will not be fired.
+func (em *Manager) PublishAfter(delay time.Duration, name string, params M) *Schedule {
+	return em.PublishAt(em.Clock.Now().Add(delay), name, params)
+}
+
+// PublishAt publish the event by name at the time.
+// the listener errors will be reported to the ErrorHandler.
+//
+// NOTE: after the manager is closed, the schedule will not be fired.
+func (em *Manager) PublishAt(at time.Time, name string, params M) *Schedule {
+	name = checkName(name)
+	s := &Schedule{at: at, name: name}
+	s.run = func() {
+		if err, e := em.Publish(name, params); err != nil {
+			em.handleError(e, err)
+		}
+	}
+
+	em.scheduler().add(s)
+	return s
+}
+
+// scheduler get the scheduler, create it on first use.
+func (em *Manager) scheduler() *scheduler {
+	em.schedOnce.Do(func() {
+		em.sched = newScheduler(em.Clock)
+	})
+	return em.sched
+}
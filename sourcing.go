@@ -0,0 +1,266 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// the meta keys of the aggregate events, set on save to the EventStore
+const (
+	MetaAggregateID      = "aggregateid"
+	MetaAggregateVersion = "aggregateversion"
+)
+
+// Aggregate is the event sourcing aggregate, the state is derived from the events.
+// embed the AggregateBase to implement the methods except Apply.
+//
+// Usage:
+// 	type Order struct {
+// 		event.AggregateBase
+// 		Status string
+// 	}
+//
+// 	func (o *Order) Apply(e event.IEvent) {
+// 		switch e.Name() {
+// 		case "order.created":
+// 			o.Status = "created"
+// 		}
+// 	}
+//
+// 	func (o *Order) Create() {
+// 		event.ApplyChange(o, event.NewBasicEvent("order.created", nil))
+// 	}
+type Aggregate interface {
+	// AggregateID the aggregate ID, it is the event stream ID.
+	AggregateID() string
+	// SetAggregateID set the aggregate ID
+	SetAggregateID(id string)
+	// Version the version of the stored events, 0 is new.
+	Version() int
+	// SetVersion set the version
+	SetVersion(v int)
+	// Apply an event to change the state
+	Apply(e IEvent)
+	// Changes the uncommitted events
+	Changes() []IEvent
+	// RecordChange add an uncommitted event
+	RecordChange(e IEvent)
+	// ClearChanges clear the uncommitted events
+	ClearChanges()
+}
+
+// AggregateSnapshotter the aggregate can implement it to control the snapshot
+// state. default will use JSON to encode the aggregate, only the exported
+// fields are kept.
+type AggregateSnapshotter interface {
+	MarshalSnapshot() ([]byte, error)
+	UnmarshalSnapshot(bs []byte) error
+}
+
+// AggregateBase the base implementation of the Aggregate, except the Apply.
+type AggregateBase struct {
+	id      string
+	version int
+	changes []IEvent
+}
+
+// AggregateID get the aggregate ID
+func (a *AggregateBase) AggregateID() string {
+	return a.id
+}
+
+// SetAggregateID set the aggregate ID
+func (a *AggregateBase) SetAggregateID(id string) {
+	a.id = id
+}
+
+// Version get the version of the stored events
+func (a *AggregateBase) Version() int {
+	return a.version
+}
+
+// SetVersion set the version
+func (a *AggregateBase) SetVersion(v int) {
+	a.version = v
+}
+
+// Changes get the uncommitted events
+func (a *AggregateBase) Changes() []IEvent {
+	return a.changes
+}
+
+// RecordChange add an uncommitted event
+func (a *AggregateBase) RecordChange(e IEvent) {
+	a.changes = append(a.changes, e)
+}
+
+// ClearChanges clear the uncommitted events
+func (a *AggregateBase) ClearChanges() {
+	a.changes = nil
+}
+
+// ApplyChange apply a new event to the aggregate, and record it as an uncommitted change.
+func ApplyChange(a Aggregate, e IEvent) {
+	a.Apply(e)
+	a.RecordChange(e)
+}
+
+// RepositoryOption the option func of the Repository
+type RepositoryOption func(r *Repository)
+
+// WithSnapshotEvery save a snapshot every n events. default is 0, no snapshot.
+//
+// NOTICE: without the AggregateSnapshotter, the snapshot is the JSON of the
+// aggregate, the unexported fields are lost on restore. implement the
+// AggregateSnapshotter if the aggregate has unexported state.
+func WithSnapshotEvery(n int) RepositoryOption {
+	return func(r *Repository) {
+		r.snapshotEvery = n
+	}
+}
+
+// Repository load and save the aggregates by an EventStore. the committed
+// events are published by the Manager.
+type Repository struct {
+	em    *Manager
+	store EventStore
+	newFn func() Aggregate
+	// save a snapshot every n events
+	snapshotEvery int
+}
+
+// NewRepository create an aggregate repository. the newFn create an empty aggregate.
+// the em can be nil, then the committed events will not be published.
+func NewRepository(em *Manager, store EventStore, newFn func() Aggregate, opts ...RepositoryOption) *Repository {
+	if store == nil {
+		panic("event: the event store cannot be nil")
+	}
+	if newFn == nil {
+		panic("event: the aggregate constructor cannot be nil")
+	}
+
+	r := &Repository{em: em, store: store, newFn: newFn}
+	for _, fn := range opts {
+		fn(r)
+	}
+	return r
+}
+
+// Load an aggregate by ID. restore from the latest snapshot, then apply the
+// events after the snapshot. return a new aggregate with version 0 if not exists.
+func (r *Repository) Load(id string) (Aggregate, error) {
+	a := r.newFn()
+	a.SetAggregateID(id)
+
+	snap, err := r.store.LoadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if snap != nil {
+		if err = unmarshalSnapshot(a, snap.State); err != nil {
+			return nil, err
+		}
+		a.SetAggregateID(id)
+		a.SetVersion(snap.Version)
+	}
+
+	es, err := r.store.Load(id, a.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range es {
+		a.Apply(e)
+	}
+	a.SetVersion(a.Version() + len(es))
+	return a, nil
+}
+
+// Save the uncommitted events of the aggregate, with the optimistic concurrency
+// check by the aggregate version. return *VersionConflictError on the stream is
+// changed by others.
+//
+// After committed, the events are published by the Manager in order. the events
+// are not rolled back on publish error, the first error is returned.
+func (r *Repository) Save(ctx context.Context, a Aggregate) error {
+	changes := a.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	id, expected := a.AggregateID(), a.Version()
+	for i, e := range changes {
+		// stamp the envelope before stored, the ID and time are persisted.
+		r.stampEnvelope(ctx, e)
+		if ms, ok := e.(metaSetter); ok {
+			ms.SetMeta(MetaAggregateID, id)
+			ms.SetMeta(MetaAggregateVersion, expected+i+1)
+		}
+	}
+
+	if err := r.store.Append(id, expected, changes); err != nil {
+		return err
+	}
+
+	version := expected + len(changes)
+	a.SetVersion(version)
+	a.ClearChanges()
+
+	var firstErr error
+	if r.snapshotEvery > 0 && version/r.snapshotEvery > expected/r.snapshotEvery {
+		firstErr = r.saveSnapshot(a)
+	}
+
+	if r.em != nil {
+		for _, e := range changes {
+			if err := r.em.publishContext(ctx, e); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// stampEnvelope fill the empty envelope fields by the manager,
+// or the RealClock and an empty source if the manager is nil.
+func (r *Repository) stampEnvelope(ctx context.Context, e IEvent) {
+	if r.em != nil {
+		r.em.stampEnvelope(ctx, e)
+	} else if s, ok := e.(envelopeStamper); ok {
+		s.stampEnvelope("", RealClock.Now(), causeFromContext(ctx))
+	}
+}
+
+// saveSnapshot save a snapshot of the aggregate
+func (r *Repository) saveSnapshot(a Aggregate) error {
+	var state []byte
+	var err error
+	if s, ok := a.(AggregateSnapshotter); ok {
+		state, err = s.MarshalSnapshot()
+	} else {
+		state, err = json.Marshal(a)
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.store.SaveSnapshot(&Snapshot{
+		AggregateID: a.AggregateID(),
+		Version:     a.Version(),
+		State:       state,
+	})
+}
+
+// unmarshalSnapshot restore the aggregate by the snapshot state
+func unmarshalSnapshot(a Aggregate, state []byte) error {
+	if s, ok := a.(AggregateSnapshotter); ok {
+		return s.UnmarshalSnapshot(state)
+	}
+	return json.Unmarshal(state, a)
+}
+
+// metaSetter set a metadata value. the BasicEvent implements it.
+type metaSetter interface {
+	SetMeta(key string, val interface{}) *BasicEvent
+}
@@ -0,0 +1,55 @@
+package event
+
+import "sync/atomic"
+
+// Stats the counters of a manager
+type Stats struct {
+	// Retries the number of listener retries
+	Retries uint64
+	// RetryFailures the number of listeners still failed after retries
+	RetryFailures uint64
+	// AsyncDropped the number of events dropped on the async queue is full
+	AsyncDropped uint64
+	// ChanDropped the number of events dropped on the subscribed channel is full
+	ChanDropped uint64
+	// RateLimited the number of events rejected by the publish rate limits
+	RateLimited uint64
+	// ListenerRateLimited the number of listener calls skipped by the listener rate limits
+	ListenerRateLimited uint64
+}
+
+func (s *Stats) addRetries(n uint64) {
+	atomic.AddUint64(&s.Retries, n)
+}
+
+func (s *Stats) addRetryFailures(n uint64) {
+	atomic.AddUint64(&s.RetryFailures, n)
+}
+
+func (s *Stats) addAsyncDropped(n uint64) {
+	atomic.AddUint64(&s.AsyncDropped, n)
+}
+
+func (s *Stats) addChanDropped(n uint64) {
+	atomic.AddUint64(&s.ChanDropped, n)
+}
+
+func (s *Stats) addRateLimited(n uint64) {
+	atomic.AddUint64(&s.RateLimited, n)
+}
+
+func (s *Stats) addListenerRateLimited(n uint64) {
+	atomic.AddUint64(&s.ListenerRateLimited, n)
+}
+
+// Stats get a copy of the manager counters
+func (em *Manager) Stats() Stats {
+	return Stats{
+		Retries:             atomic.LoadUint64(&em.stats.Retries),
+		RetryFailures:       atomic.LoadUint64(&em.stats.RetryFailures),
+		AsyncDropped:        atomic.LoadUint64(&em.stats.AsyncDropped),
+		ChanDropped:         atomic.LoadUint64(&em.stats.ChanDropped),
+		RateLimited:         atomic.LoadUint64(&em.stats.RateLimited),
+		ListenerRateLimited: atomic.LoadUint64(&em.stats.ListenerRateLimited),
+	}
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_AsyncPublish_errorHandler(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+
+	em := event.NewManager("test", event.WithConsumerNum(2), event.WithErrorHandler(func(e event.IEvent, err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		return fmt.Errorf("an error")
+	}))
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	}
+
+	assert.NoError(t, em.Close())
+	assert.Len(t, errs, 5)
+	assert.Equal(t, "an error", errs[0].Error())
+
+	// closed
+	assert.Equal(t, event.ErrManagerClosed, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	assert.Equal(t, event.ErrManagerClosed, em.Close())
+}
+
+func TestManager_AsyncPublish_overflow(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	var dropped int32
+	em := event.NewManager(
+		"test",
+		event.WithConsumerNum(1),
+		event.WithChannelSize(1),
+		event.WithOverflow(event.OverflowError),
+		event.WithErrorHandler(func(e event.IEvent, err error) {
+			if err == event.ErrQueueFull {
+				atomic.AddInt32(&dropped, 1)
+			}
+		}),
+	)
+
+	var once sync.Once
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		once.Do(func() { close(started) })
+		<-block
+		return nil
+	}))
+
+	// the first is taken by worker, the second stays in the queue.
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	<-started
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	assert.Equal(t, 1, em.QueueLen())
+
+	assert.Equal(t, event.ErrQueueFull, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+
+	// drop policy
+	em.Overflow = event.OverflowDrop
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dropped))
+
+	close(block)
+	assert.NoError(t, em.Close())
+	assert.Equal(t, 0, em.QueueLen())
+}
+
+func TestManager_Close_drain(t *testing.T) {
+	var handled int32
+	em := event.NewManager("test", event.WithConsumerNum(3), event.WithChannelSize(50))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}))
+
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	}
+
+	assert.NoError(t, em.Close())
+	assert.Equal(t, int32(50), atomic.LoadInt32(&handled))
+}
+
+func TestManager_Close_notStarted(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(3))
+	assert.Equal(t, 0, em.QueueLen())
+
+	before := runtime.NumGoroutine()
+	assert.NoError(t, em.Close())
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+	assert.ErrorIs(t, em.Close(), event.ErrManagerClosed)
+
+	// not start the workers after closed
+	assert.ErrorIs(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)), event.ErrManagerClosed)
+	assert.Equal(t, 0, em.QueueLen())
+}
+
+func TestManager_QueueLen_concurrent(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1))
+	defer em.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = em.QueueLen()
+		}
+	}()
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	<-done
+}
+
+func TestManager_Close_chainedAsyncPublish(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1), event.WithChannelSize(1))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		time.Sleep(time.Millisecond)
+		_ = em.AsyncPublish(event.NewBasicEvent("e2", nil))
+		_ = em.QueueLen()
+		return nil
+	}))
+
+	go func() {
+		for i := 0; i < 20; i++ {
+			if em.AsyncPublish(event.NewBasicEvent("e1", nil)) != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- em.Close()
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the Close is blocked")
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/bychannel/event"
@@ -47,3 +48,60 @@ func BenchmarkManager_Publish_wildcard(b *testing.B) {
 		_, _ = em.Publish("aa.bb", nil)
 	}
 }
+
+// newBenchManager create a manager with many event names and patterns
+func newBenchManager() *event.Manager {
+	em := event.NewManager("test")
+	fn := event.ListenerFunc(func(e event.IEvent) error {
+		return nil
+	})
+
+	for i := 0; i < 200; i++ {
+		em.Listen(fmt.Sprintf("app.mod%d.evt%d", i%20, i), fn, i%5*100)
+		em.Listen(fmt.Sprintf("app.mod%d.*", i%20), fn)
+	}
+	em.Listen("app.**", fn)
+	em.Listen("app.*.evt1", fn)
+	em.Listen("*", fn)
+	return em
+}
+
+func BenchmarkManager_ListenersFor(b *testing.B) {
+	em := newBenchManager()
+	// warm up the cache
+	items := em.ListenersFor("app.mod1.evt1")
+	if len(items) == 0 {
+		b.Fatal("no listeners matched")
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = em.ListenersFor("app.mod1.evt1")
+	}
+}
+
+func BenchmarkManager_Publish_manyPatterns(b *testing.B) {
+	em := newBenchManager()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = em.Publish("app.mod1.evt1", nil)
+	}
+}
+
+func BenchmarkManager_Publish_parallel(b *testing.B) {
+	em := newBenchManager()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = em.Publish("app.mod1.evt1", nil)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_SubscribeChan(t *testing.T) {
+	em := event.NewManager("test")
+	ch, cancel := em.SubscribeChan("order.*", 10, event.OverflowBlock)
+
+	var got []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for e := range ch {
+			got = append(got, e.Name())
+		}
+	}()
+
+	_, _ = em.Publish("order.created", nil)
+	_, _ = em.Publish("order.paid", nil)
+	_, _ = em.Publish("user.created", nil)
+
+	cancel()
+	wg.Wait()
+	assert.Equal(t, []string{"order.created", "order.paid"}, got)
+	assert.False(t, em.HasListeners("order.*"))
+
+	// cancel again is ok
+	cancel()
+}
+
+func TestManager_SubscribeChan_overflow(t *testing.T) {
+	em := event.NewManager("test")
+
+	newest, cancel1 := em.SubscribeChan("e1", 2, event.OverflowDrop)
+	defer cancel1()
+	oldest, cancel2 := em.SubscribeChan("e1", 2, event.OverflowDropOldest)
+	defer cancel2()
+
+	for i := 0; i < 4; i++ {
+		_, _ = em.Publish("e1", event.M{"n": i})
+	}
+
+	assert.Equal(t, 0, (<-newest).Get("n"))
+	assert.Equal(t, 1, (<-newest).Get("n"))
+	assert.Equal(t, 2, (<-oldest).Get("n"))
+	assert.Equal(t, 3, (<-oldest).Get("n"))
+	assert.Equal(t, uint64(4), em.Stats().ChanDropped)
+
+	// error policy
+	_, cancel3 := em.SubscribeChan("e2", 1, event.OverflowError)
+	defer cancel3()
+	err, _ := em.Publish("e2", nil)
+	assert.NoError(t, err)
+	err, _ = em.Publish("e2", nil)
+	assert.Equal(t, event.ErrQueueFull, err)
+	assert.Equal(t, uint64(5), em.Stats().ChanDropped)
+}
+
+func TestManager_SubscribeChan_block(t *testing.T) {
+	em := event.NewManager("test")
+	_, cancel := em.SubscribeChan("e1", 0, event.OverflowBlock)
+
+	// blocked by the ctx
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelCtx()
+	err, _ := em.PublishContext(ctx, "e1", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// released by cancel
+	done := make(chan error)
+	go func() {
+		err, _ := em.Publish("e1", nil)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestManager_AsyncPublish_dropOldest(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	var mu sync.Mutex
+	var dropped []interface{}
+	em := event.NewManager(
+		"test",
+		event.WithConsumerNum(1),
+		event.WithChannelSize(1),
+		event.WithOverflow(event.OverflowDropOldest),
+		event.WithErrorHandler(func(e event.IEvent, err error) {
+			mu.Lock()
+			dropped = append(dropped, e.Get("n"))
+			mu.Unlock()
+		}),
+	)
+
+	var once sync.Once
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		once.Do(func() { close(started) })
+		<-block
+		return nil
+	}))
+
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", event.M{"n": 0})))
+	<-started
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", event.M{"n": i})))
+	}
+
+	close(block)
+	assert.NoError(t, em.Close())
+	assert.Equal(t, []interface{}{1, 2}, dropped)
+	assert.Equal(t, uint64(2), em.Stats().AsyncDropped)
+}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// examples from the CloudEvents 1.0 JSON format spec
+const (
+	ceJSONExample = `{
+    "specversion" : "1.0",
+    "type" : "com.example.someevent",
+    "source" : "/mycontext",
+    "id" : "A234-1234-1234",
+    "time" : "2018-04-05T17:31:00Z",
+    "comexampleextension1" : "value",
+    "comexampleothervalue" : 5,
+    "datacontenttype" : "application/json",
+    "data" : {
+        "appinfoA" : "abc",
+        "appinfoB" : 123,
+        "appinfoC" : true
+    }
+}`
+	ceXMLExample = `{
+    "specversion" : "1.0",
+    "type" : "com.github.pull_request.opened",
+    "source" : "https://github.com/cloudevents/spec/pull",
+    "subject" : "123",
+    "id" : "A234-1234-1234",
+    "time" : "2018-04-05T17:31:00Z",
+    "comexampleextension1" : "value",
+    "comexampleothervalue" : 5,
+    "datacontenttype" : "text/xml",
+    "data" : "<much wow=\"xml\"/>"
+}`
+	ceBase64Example = `{
+    "specversion" : "1.0",
+    "type" : "com.example.someevent",
+    "source" : "/mycontext",
+    "id" : "A234-1234-1234",
+    "time" : "2018-04-05T17:31:00Z",
+    "datacontenttype" : "application/vnd.apache.thrift.binary",
+    "data_base64" : "AQIDBA=="
+}`
+)
+
+func TestUnmarshalCloudEvent(t *testing.T) {
+	e, err := event.UnmarshalCloudEvent([]byte(ceJSONExample))
+	assert.NoError(t, err)
+	assert.Equal(t, "com.example.someevent", e.Name())
+	assert.Equal(t, "A234-1234-1234", e.ID())
+	assert.Equal(t, "/mycontext", e.Source())
+	assert.Equal(t, time.Date(2018, 4, 5, 17, 31, 0, 0, time.UTC), e.Time().UTC())
+	assert.Equal(t, "abc", e.Get("appinfoA"))
+	assert.Equal(t, float64(123), e.Get("appinfoB"))
+	assert.Equal(t, true, e.Get("appinfoC"))
+	assert.Equal(t, "value", e.GetMeta("comexampleextension1"))
+	assert.Equal(t, float64(5), e.GetMeta("comexampleothervalue"))
+	assert.Equal(t, "application/json", e.GetMeta("datacontenttype"))
+
+	e, err = event.UnmarshalCloudEvent([]byte(ceXMLExample))
+	assert.NoError(t, err)
+	assert.Equal(t, "123", e.GetMeta("subject"))
+	assert.Equal(t, `<much wow="xml"/>`, e.Get(event.PayloadKey))
+
+	e, err = event.UnmarshalCloudEvent([]byte(ceBase64Example))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, e.Get(event.PayloadKey))
+
+	// invalid
+	for _, s := range []string{
+		`not json`,
+		`{"specversion":"0.3","type":"a","source":"b","id":"c"}`,
+		`{"specversion":"1.0","type":"a","source":"b"}`,
+		`{"specversion":"1.0","type":"a","source":"b","id":"c","time":"yesterday"}`,
+		`{"specversion":"1.0","type":"a","source":"b","id":"c","data_base64":"!!"}`,
+	} {
+		_, err = event.UnmarshalCloudEvent([]byte(s))
+		assert.ErrorIs(t, err, event.ErrInvalidCloudEvent, s)
+	}
+}
+
+func TestMarshalCloudEvent_roundTrip(t *testing.T) {
+	for _, example := range []string{ceJSONExample, ceXMLExample, ceBase64Example} {
+		e, err := event.UnmarshalCloudEvent([]byte(example))
+		assert.NoError(t, err)
+
+		bs, err := event.MarshalCloudEvent(e)
+		assert.NoError(t, err)
+		assert.JSONEq(t, example, string(bs))
+	}
+}
+
+func TestMarshalCloudEvent(t *testing.T) {
+	em := event.NewManager("orders")
+	em.Listen("order.created", event.ListenerFunc(emptyListener))
+
+	_, parent := em.Publish("order.created", event.M{"id": 1})
+	e := event.NewBasicEvent("order.paid", event.M{"amount": 10})
+	e.CausedBy(parent).SetMeta("traceparent", "00-abc")
+	assert.NoError(t, em.AwaitPublish(e))
+
+	bs, err := event.MarshalCloudEvent(e)
+	assert.NoError(t, err)
+
+	e2, err := event.UnmarshalCloudEvent(bs)
+	assert.NoError(t, err)
+	assert.Equal(t, e.Name(), e2.Name())
+	assert.Equal(t, e.ID(), e2.ID())
+	assert.Equal(t, "orders", e2.Source())
+	assert.True(t, e.Time().Equal(e2.Time()))
+	assert.Equal(t, parent.(event.IEnvelope).ID(), e2.CorrelationID())
+	assert.Equal(t, parent.(event.IEnvelope).ID(), e2.CausationID())
+	assert.Equal(t, "00-abc", e2.GetMeta("traceparent"))
+	assert.Equal(t, float64(10), e2.Get("amount"))
+
+	// missing the id and source
+	_, err = event.MarshalCloudEvent(event.NewBasicEvent("evt", nil))
+	assert.ErrorIs(t, err, event.ErrInvalidCloudEvent)
+
+	// invalid meta key
+	e.SetMeta("Bad-Key", 1)
+	_, err = event.MarshalCloudEvent(e)
+	assert.ErrorIs(t, err, event.ErrInvalidCloudEvent)
+}
+
+func TestCloudEventFromHTTP_binary(t *testing.T) {
+	// example from the CloudEvents 1.0 HTTP binding spec
+	h := http.Header{}
+	h.Set("ce-specversion", "1.0")
+	h.Set("ce-type", "com.example.someevent")
+	h.Set("ce-time", "2018-04-05T03:56:24Z")
+	h.Set("ce-id", "1234-1234-1234")
+	h.Set("ce-source", "/mycontext/subcontext")
+	h.Set("ce-comexampleextension1", "hello%20world%25")
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	body := []byte(`{"appinfoA": "abc"}`)
+
+	e, err := event.CloudEventFromHTTP(h, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "com.example.someevent", e.Name())
+	assert.Equal(t, "1234-1234-1234", e.ID())
+	assert.Equal(t, "/mycontext/subcontext", e.Source())
+	assert.Equal(t, "abc", e.Get("appinfoA"))
+	assert.Equal(t, "hello world%", e.GetMeta("comexampleextension1"))
+
+	h2, body2, err := event.CloudEventToHTTP(e)
+	assert.NoError(t, err)
+	assert.Equal(t, h, h2)
+	assert.JSONEq(t, string(body), string(body2))
+
+	// invalid
+	h.Set("ce-specversion", "")
+	_, err = event.CloudEventFromHTTP(h, body)
+	assert.ErrorIs(t, err, event.ErrInvalidCloudEvent)
+}
+
+func TestCloudEventToHTTP(t *testing.T) {
+	e, err := event.UnmarshalCloudEvent([]byte(ceXMLExample))
+	assert.NoError(t, err)
+
+	h, body, err := event.CloudEventToHTTP(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/xml", h.Get("Content-Type"))
+	assert.Equal(t, "123", h.Get("ce-subject"))
+	assert.Equal(t, "5", h.Get("ce-comexampleothervalue"))
+	assert.Equal(t, `<much wow="xml"/>`, string(body))
+
+	e2, err := event.CloudEventFromHTTP(h, body)
+	assert.NoError(t, err)
+	assert.Equal(t, e.Data(), e2.Data())
+	assert.Equal(t, e.ID(), e2.ID())
+
+	// binary data
+	e, err = event.UnmarshalCloudEvent([]byte(ceBase64Example))
+	assert.NoError(t, err)
+	h, body, err = event.CloudEventToHTTP(e)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, body)
+
+	e2, err = event.CloudEventFromHTTP(h, body)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, e2.Get(event.PayloadKey))
+
+	// structured mode
+	bs, err := event.MarshalCloudEvent(e)
+	assert.NoError(t, err)
+	h = http.Header{"Content-Type": {event.CloudEventsContentType}}
+	e2, err = event.CloudEventFromHTTP(h, bs)
+	assert.NoError(t, err)
+	assert.Equal(t, e.ID(), e2.ID())
+}
@@ -0,0 +1,222 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderEvent struct {
+	event.BasicEvent
+}
+
+// paidEvent has the own fields
+type paidEvent struct {
+	event.BasicEvent
+	OrderID string
+	Amount  float64
+	Items   []string
+	secret  string
+}
+
+// badEvent the field cannot be encoded as JSON
+type badEvent struct {
+	event.BasicEvent
+	Ch chan int
+}
+
+// wrapEvent not embed the BasicEvent
+type wrapEvent struct {
+	event.IEvent
+}
+
+func (e *orderEvent) OrderID() interface{} {
+	return e.Get("order_id")
+}
+
+func newCodecEvent() *event.BasicEvent {
+	e := event.NewBasicEvent("order.created", event.M{
+		"str":   "abc",
+		"bool":  true,
+		"float": 1.5,
+		"list":  []interface{}{"a", "b"},
+		"map":   map[string]interface{}{"k": "v"},
+	})
+	e.SetID(event.NewID()).SetSource("orders").SetTime(time.Unix(1700000000, 123456789))
+	e.SetCorrelationID("corr").SetCausationID("cause").SetMeta("trace", "t1")
+	return e
+}
+
+func TestEventCodecs(t *testing.T) {
+	for _, name := range []string{"json", "gob", "msgpack"} {
+		c := event.GetCodec(name)
+		assert.NotNil(t, c, name)
+		assert.Equal(t, name, c.Name())
+
+		e := newCodecEvent()
+		bs, err := c.Encode(e)
+		assert.NoError(t, err, name)
+
+		de, err := c.Decode(bs)
+		assert.NoError(t, err, name)
+
+		got, ok := de.(*event.BasicEvent)
+		assert.True(t, ok, name)
+		assert.Equal(t, e.Name(), got.Name(), name)
+		assert.Equal(t, e.ID(), got.ID(), name)
+		assert.Equal(t, e.Source(), got.Source(), name)
+		assert.Equal(t, "corr", got.CorrelationID(), name)
+		assert.Equal(t, "cause", got.CausationID(), name)
+		assert.True(t, e.Time().Equal(got.Time()), name)
+		assert.Equal(t, e.Meta(), got.Meta(), name)
+		assert.Equal(t, e.Data(), got.Data(), name)
+
+		// decode error
+		_, err = c.Decode([]byte{0xc1})
+		assert.Error(t, err, name)
+	}
+
+	assert.Nil(t, event.GetCodec("not-exist"))
+}
+
+func TestMsgpackCodec_values(t *testing.T) {
+	c := &event.MsgpackCodec{}
+	e := event.NewBasicEvent("evt", event.M{
+		"nil":    nil,
+		"int":    -1,
+		"int8":   int8(-100),
+		"int16":  -1000,
+		"int32":  -100000,
+		"int64":  int64(-1) << 40,
+		"uint":   uint(200),
+		"uint16": uint16(60000),
+		"uint32": uint32(1) << 31,
+		"uint64": uint64(1) << 63,
+		"f32":    float32(1.5),
+		"bin":    []byte{1, 2, 3},
+		"strs":   []string{"a", "b"},
+		"long":   string(make([]byte, 300)),
+		"time":   time.Unix(100, 5),
+		"nested": map[string]int{"a": 1},
+	})
+
+	bs, err := c.Encode(e)
+	assert.NoError(t, err)
+	// stable bytes
+	bs2, _ := c.Encode(e)
+	assert.Equal(t, bs, bs2)
+
+	de, err := c.Decode(bs)
+	assert.NoError(t, err)
+	assert.Nil(t, de.Get("nil"))
+	assert.Equal(t, int64(-1), de.Get("int"))
+	assert.Equal(t, int64(-100), de.Get("int8"))
+	assert.Equal(t, int64(-1000), de.Get("int16"))
+	assert.Equal(t, int64(-100000), de.Get("int32"))
+	assert.Equal(t, int64(-1)<<40, de.Get("int64"))
+	assert.Equal(t, int64(200), de.Get("uint"))
+	assert.Equal(t, int64(60000), de.Get("uint16"))
+	assert.Equal(t, int64(1)<<31, de.Get("uint32"))
+	assert.Equal(t, uint64(1)<<63, de.Get("uint64"))
+	assert.Equal(t, float32(1.5), de.Get("f32"))
+	assert.Equal(t, []byte{1, 2, 3}, de.Get("bin"))
+	assert.Equal(t, []interface{}{"a", "b"}, de.Get("strs"))
+	assert.Len(t, de.Get("long"), 300)
+	assert.True(t, time.Unix(100, 5).Equal(de.Get("time").(time.Time)))
+	assert.Equal(t, event.M{"a": int64(1)}, de.Get("nested"))
+
+	// unsupported
+	_, err = c.Encode(event.NewBasicEvent("evt", event.M{"ch": make(chan int)}))
+	assert.Error(t, err)
+
+	// truncated
+	_, err = c.Decode(bs[:len(bs)/2])
+	assert.Error(t, err)
+	// not a map
+	_, err = c.Decode([]byte{0x01})
+	assert.Error(t, err)
+}
+
+func TestTypeRegistry(t *testing.T) {
+	types := event.NewTypeRegistry()
+	types.Register("order", func() event.IEvent { return &orderEvent{} })
+	assert.Equal(t, []string{"order"}, types.Types())
+
+	assert.Panics(t, func() {
+		types.Register("order", func() event.IEvent { return &orderEvent{} })
+	})
+	assert.Panics(t, func() {
+		types.Register("", func() event.IEvent { return &orderEvent{} })
+	})
+	assert.Panics(t, func() {
+		types.Register("user", func() event.IEvent { return &wrapEvent{} })
+	})
+
+	oe := &orderEvent{}
+	oe.SetName("order.created")
+	oe.SetData(event.M{"order_id": "o1"})
+	oe.SetID("id1")
+	assert.Equal(t, "order", types.TypeOf(oe))
+	assert.Equal(t, "", types.TypeOf(event.NewBasicEvent("evt", nil)))
+
+	codecs := []event.EventCodec{
+		&event.JSONCodec{Types: types},
+		&event.GobCodec{Types: types},
+		&event.MsgpackCodec{Types: types},
+	}
+	for _, c := range codecs {
+		bs, err := c.Encode(oe)
+		assert.NoError(t, err, c.Name())
+
+		de, err := c.Decode(bs)
+		assert.NoError(t, err, c.Name())
+		got, ok := de.(*orderEvent)
+		assert.True(t, ok, c.Name())
+		assert.Equal(t, "order.created", got.Name())
+		assert.Equal(t, "o1", got.OrderID())
+		assert.Equal(t, "id1", got.ID())
+
+		// the type is not registered in the DefaultTypes
+		_, err = event.GetCodec(c.Name()).Decode(bs)
+		assert.ErrorIs(t, err, event.ErrUnknownEventType, c.Name())
+	}
+}
+
+func TestTypeRegistry_fields(t *testing.T) {
+	types := event.NewTypeRegistry()
+	types.Register("paid", func() event.IEvent { return &paidEvent{} })
+	types.Register("bad", func() event.IEvent { return &badEvent{} })
+
+	pe := &paidEvent{OrderID: "o1", Amount: 9.5, Items: []string{"a", "b"}, secret: "s"}
+	pe.SetName("order.paid")
+	pe.SetData(event.M{"k": "v"})
+
+	codecs := []event.EventCodec{
+		&event.JSONCodec{Types: types},
+		&event.GobCodec{Types: types},
+		&event.MsgpackCodec{Types: types},
+	}
+	for _, c := range codecs {
+		bs, err := c.Encode(pe)
+		assert.NoError(t, err, c.Name())
+
+		de, err := c.Decode(bs)
+		assert.NoError(t, err, c.Name())
+		got, ok := de.(*paidEvent)
+		assert.True(t, ok, c.Name())
+		assert.Equal(t, "order.paid", got.Name())
+		assert.Equal(t, "v", got.Get("k"))
+		assert.Equal(t, "o1", got.OrderID, c.Name())
+		assert.Equal(t, 9.5, got.Amount, c.Name())
+		assert.Equal(t, []string{"a", "b"}, got.Items, c.Name())
+		// the unexported fields are not encoded
+		assert.Equal(t, "", got.secret)
+
+		be := &badEvent{Ch: make(chan int)}
+		be.SetName("evt")
+		_, err = c.Encode(be)
+		assert.Error(t, err, c.Name())
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// run with: go test -race ./test/
+func TestManager_concurrent_listen_publish(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(4))
+
+	var handled int64
+	counter := event.ListenerFunc(func(e event.IEvent) error {
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+	em.Listen("app.evt", counter)
+
+	var wg sync.WaitGroup
+	// register and remove listeners
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				s1 := em.Listen("app.evt", event.ListenerFunc(emptyListener), j%3*100)
+				s2 := em.Listen("app.*", event.ListenerFunc(emptyListener))
+				s3 := em.Listen("*", event.ListenerFunc(emptyListener))
+				em.AddEvent(event.NewBasicEvent("other", nil))
+				s1.Unsubscribe()
+				s2.Unsubscribe()
+				s3.Unsubscribe()
+				em.RemoveEvent("other")
+			}
+		}()
+	}
+
+	// publish events
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				_, _ = em.Publish("app.evt", nil)
+				_ = em.AsyncPublish(event.NewBasicEvent("app.evt", nil))
+				_ = em.HasListeners("app.*")
+				_ = em.ListenersCount("app.evt")
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.NoError(t, em.Close())
+
+	assert.Equal(t, int64(1600), atomic.LoadInt64(&handled))
+	assert.Equal(t, 1, em.ListenersCount("app.evt"))
+	assert.False(t, em.HasListeners("app.*"))
+	assert.False(t, em.HasEvent("other"))
+}
+
+func TestManager_concurrent_listen_in_listener(t *testing.T) {
+	em := event.NewManager("test")
+
+	var subs []*event.Subscription
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		// modify listeners on publishing does not affect the current publish.
+		subs = append(subs, em.Listen("e1", event.ListenerFunc(emptyListener), event.Max))
+		return nil
+	}))
+
+	err, _ := em.Publish("e1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, subs, 1)
+	assert.Equal(t, 2, em.ListenersCount("e1"))
+}
+
+func TestListenerQueue_sorted_on_add(t *testing.T) {
+	em := event.NewManager("test")
+	em.Listen("e1", event.ListenerFunc(emptyListener), event.Low)
+	s2 := em.Listen("e1", event.ListenerFunc(emptyListener), event.High)
+	s3 := em.Listen("e1", event.ListenerFunc(emptyListener), event.Normal)
+	s4 := em.Listen("e1", event.ListenerFunc(emptyListener), event.High)
+
+	items := em.ListenersByName("e1").Items()
+	assert.Len(t, items, 4)
+	assert.Equal(t, s2.ID(), items[0].ID)
+	assert.Equal(t, s4.ID(), items[1].ID)
+	assert.Equal(t, s3.ID(), items[2].ID)
+}
+
+func TestManager_Listeners_copy(t *testing.T) {
+	em := event.NewManager("test")
+	em.Listen("e1", event.ListenerFunc(emptyListener))
+	em.Listen("e1", event.ListenerFunc(emptyListener))
+
+	// modify the returned queues does not affect the manager
+	em.ListenersByName("e1").Clear()
+	em.Listeners()["e1"].Push(&event.ListenerItem{Listener: event.ListenerFunc(emptyListener)})
+	assert.Equal(t, 2, em.ListenersCount("e1"))
+	assert.Len(t, em.ListenersFor("e1"), 2)
+	assert.Nil(t, em.ListenersByName("not-exist"))
+
+	names := em.ListenedNames()
+	names["e2"] = 1
+	delete(names, "e1")
+	assert.True(t, em.HasListeners("e1"))
+	assert.False(t, em.HasListeners("e2"))
+}
+
+func TestManager_concurrent_reset_publish(t *testing.T) {
+	em := event.NewManager("test")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				em.Listen("e1", event.ListenerFunc(emptyListener))
+				_, _ = em.Publish("e1", nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				em.Reset()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_PublishContext(t *testing.T) {
+	em := event.NewManager("test")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var called []string
+	em.Listen("e1", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		called = append(called, "l1")
+		cancel()
+		return nil
+	}), event.High)
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l2")
+		return nil
+	}), event.Low)
+
+	err, e := em.PublishContext(ctx, "e1", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "e1", e.Name())
+	assert.Equal(t, []string{"l1"}, called)
+
+	// background ctx
+	called = called[:0]
+	err, _ = em.Publish("e1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l1", "l2"}, called)
+}
+
+func TestManager_AwaitPublishContext(t *testing.T) {
+	em := event.NewManager("test")
+	release := make(chan struct{})
+	em.Listen("e1", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := em.AwaitPublishContext(ctx, event.NewBasicEvent("e1", nil))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	err = em.AwaitPublishContext(context.Background(), event.NewBasicEvent("e1", nil))
+	assert.NoError(t, err)
+}
+
+func TestManager_BatchPublishContext(t *testing.T) {
+	em := event.NewManager("test")
+	em.Listen("e1", event.ListenerFunc(emptyListener))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ers := em.BatchPublishContext(ctx, "e1", "e1")
+	assert.Len(t, ers, 1)
+	assert.ErrorIs(t, ers[0], context.Canceled)
+}
+
+func TestToContextListener(t *testing.T) {
+	var n int
+	cl := event.ToContextListener(event.ListenerFunc(func(e event.IEvent) error {
+		n++
+		return nil
+	}))
+
+	assert.NoError(t, cl.HandleContext(context.Background(), event.NewBasicEvent("e1", nil)))
+	assert.Equal(t, 1, n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, cl.HandleContext(ctx, event.NewBasicEvent("e1", nil)), context.Canceled)
+	assert.Equal(t, 1, n)
+
+	fn := event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		return nil
+	})
+	assert.NotNil(t, event.ToContextListener(fn))
+}
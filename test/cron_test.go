@@ -0,0 +1,112 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	// 2022-01-01 is Saturday
+	base := time.Date(2022, 1, 1, 10, 30, 15, 0, time.UTC)
+	date := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2022, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", date(1, 1, 10, 31)},
+		{"*/15 * * * *", date(1, 1, 10, 45)},
+		{"0 * * * *", date(1, 1, 11, 0)},
+		{"@hourly", date(1, 1, 11, 0)},
+		{"@daily", date(1, 2, 0, 0)},
+		{"@weekly", date(1, 2, 0, 0)},
+		{"@monthly", date(2, 1, 0, 0)},
+		{"@yearly", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9-18 * * 1-5", date(1, 3, 9, 0)},
+		{"0 9 * * MON-FRI", date(1, 3, 9, 0)},
+		{"30 8 * * 7", date(1, 2, 8, 30)},
+		{"0 0 1 JUL *", date(7, 1, 0, 0)},
+		{"5,10 10 * * *", time.Date(2022, 1, 2, 10, 5, 0, 0, time.UTC)},
+		{"40/10 10 * * *", date(1, 1, 10, 40)},
+		// day-of-month OR day-of-week
+		{"0 0 15 * MON", date(1, 3, 0, 0)},
+		{"0 0 31 2 *", time.Time{}},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		cs, err := event.ParseCron(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, cs.Next(base), tt.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "5-1 * * * *", "*/0 * * * *", "@every x", "@every -1s", "* * * FOO *"} {
+		_, err := event.ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestManager_Cron(t *testing.T) {
+	clock := event.NewFakeClock(time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC))
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var runs []int
+	var times []time.Time
+	em.Listen("system.tick.*", event.ListenerFunc(func(e event.IEvent) error {
+		runs = append(runs, e.Get("run_count").(int))
+		times = append(times, e.Get("scheduled_at").(time.Time))
+		return nil
+	}))
+
+	job, err := em.Cron("@hourly", "system.tick.hourly")
+	assert.NoError(t, err)
+	assert.Equal(t, "system.tick.hourly", job.Name())
+	assert.Equal(t, time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC), job.Next())
+
+	clock.Advance(time.Hour)
+	clock.Advance(time.Hour)
+	assert.Equal(t, []int{1, 2}, runs)
+	assert.Equal(t, time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC), times[1])
+	assert.Equal(t, 2, job.RunCount())
+
+	job.Stop()
+	assert.True(t, job.Next().IsZero())
+	clock.Advance(time.Hour)
+	assert.Equal(t, 2, job.RunCount())
+
+	_, err = em.Cron("invalid", "system.tick")
+	assert.Error(t, err)
+
+	// never fires
+	job, err = em.Cron("0 0 30 2 *", "system.tick")
+	assert.Error(t, err)
+	assert.Nil(t, job)
+}
+
+func TestManager_Cron_jitter(t *testing.T) {
+	start := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := event.NewFakeClock(start)
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var times []time.Time
+	em.Listen("job.sync", event.ListenerFunc(func(e event.IEvent) error {
+		times = append(times, e.Get("scheduled_at").(time.Time))
+		return nil
+	}))
+
+	job, err := em.Cron("@every 1m", "job.sync", event.WithCronJitter(10*time.Second))
+	assert.NoError(t, err)
+	defer job.Stop()
+
+	next := job.Next()
+	assert.True(t, !next.Before(start.Add(time.Minute)) && next.Before(start.Add(70*time.Second)))
+
+	clock.Advance(70 * time.Second)
+	// the scheduled time has no jitter
+	assert.Equal(t, []time.Time{start.Add(time.Minute)}, times)
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_DeadLetter(t *testing.T) {
+	dlq := event.NewMemoryDeadLetters()
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithDeadLetter(dlq), event.WithClock(clock))
+
+	fixed := false
+	var handled []string
+	sub := em.ListenItem("order.paid", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			if !fixed {
+				return errTemporary
+			}
+			handled = append(handled, e.Get("id").(string))
+			return nil
+		}),
+		Retry: &event.RetryPolicy{MaxAttempts: 2},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err, _ := em.Publish("order.paid", event.M{"id": "1001"})
+		assert.ErrorIs(t, err, errTemporary)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(0)
+	<-done
+
+	assert.Equal(t, 1, dlq.Len())
+	dl := dlq.List()[0]
+	assert.Equal(t, "order.paid", dl.Event.Name())
+	assert.Equal(t, sub.ID(), dl.ListenerID)
+	assert.Equal(t, 2, dl.Attempts)
+	assert.ErrorIs(t, dl.Err, errTemporary)
+	assert.Equal(t, clock.Now(), dl.Time)
+
+	// fix the bug, then re-publish
+	fixed = true
+	ers := em.RepublishDeadLetters(context.Background(), dlq)
+	assert.Empty(t, ers)
+	assert.Equal(t, []string{"1001"}, handled)
+	assert.Equal(t, 0, dlq.Len())
+}
+
+func TestManager_DeadLetter_AsyncPublish(t *testing.T) {
+	dlq := event.NewMemoryDeadLetters()
+	em := event.NewManager("test", event.WithDeadLetter(dlq))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", event.M{"k": "v"})))
+	assert.NoError(t, em.Close())
+
+	dls, err := dlq.Drain()
+	assert.NoError(t, err)
+	assert.Len(t, dls, 1)
+	assert.Equal(t, "v", dls[0].Event.Get("k"))
+	assert.Equal(t, 1, dls[0].Attempts)
+}
+
+func TestFileDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	dlq := event.NewFileDeadLetters(path)
+
+	// not exists
+	dls, err := dlq.Drain()
+	assert.NoError(t, err)
+	assert.Empty(t, dls)
+
+	em := event.NewManager("test", event.WithDeadLetter(dlq))
+	failed := true
+	var handled int
+	em.Listen("user.*", event.ListenerFunc(func(e event.IEvent) error {
+		if failed {
+			return errNotify
+		}
+		handled++
+		return nil
+	}))
+
+	_, _ = em.Publish("user.created", event.M{"name": "inhere"})
+	_, _ = em.Publish("user.deleted", event.M{"name": "tom"})
+
+	// reload from the file
+	dlq = event.NewFileDeadLetters(path)
+	dls, err = dlq.Drain()
+	assert.NoError(t, err)
+	assert.Len(t, dls, 2)
+	assert.Equal(t, "user.created", dls[0].Event.Name())
+	assert.Equal(t, "inhere", dls[0].Event.Get("name"))
+	assert.Equal(t, errNotify.Error(), dls[0].Err.Error())
+	assert.Equal(t, 1, dls[1].Attempts)
+
+	// the file is truncated
+	dls, err = dlq.Drain()
+	assert.NoError(t, err)
+	assert.Empty(t, dls)
+
+	// put back and re-publish
+	for _, name := range []string{"user.created", "user.deleted"} {
+		_, _ = em.Publish(name, nil)
+	}
+	failed = false
+	assert.Empty(t, em.RepublishDeadLetters(context.Background(), dlq))
+	assert.Equal(t, 2, handled)
+}
+
+func TestManager_RepublishDeadLetters_failedListener(t *testing.T) {
+	dlq := event.NewMemoryDeadLetters()
+	em := event.NewManager("test", event.WithDeadLetter(dlq))
+
+	var healthy, fixed int
+	failed := true
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		healthy++
+		return nil
+	}))
+	sub := em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		if failed {
+			return errNotify
+		}
+		fixed++
+		return nil
+	}))
+
+	_, _ = em.Publish("evt", nil)
+	assert.Equal(t, 1, dlq.Len())
+
+	// the ctx is done, the letters are kept
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ers := em.RepublishDeadLetters(ctx, dlq)
+	assert.Len(t, ers, 1)
+	assert.ErrorIs(t, ers[0], context.Canceled)
+	assert.Equal(t, 1, dlq.Len())
+
+	// failed again, put back with the new failure info
+	ers = em.RepublishDeadLetters(context.Background(), dlq)
+	assert.Len(t, ers, 1)
+	assert.ErrorIs(t, ers[0], errNotify)
+	assert.Equal(t, 1, dlq.Len())
+	assert.Equal(t, 2, dlq.List()[0].Attempts)
+
+	// only the failed listener is called
+	failed = false
+	assert.Empty(t, em.RepublishDeadLetters(context.Background(), dlq))
+	assert.Equal(t, 1, healthy)
+	assert.Equal(t, 1, fixed)
+	assert.Equal(t, 0, dlq.Len())
+
+	// the listener is removed, the letter is kept
+	failed = true
+	_, _ = em.Publish("evt", nil)
+	sub.Unsubscribe()
+	ers = em.RepublishDeadLetters(context.Background(), dlq)
+	assert.Len(t, ers, 1)
+	assert.ErrorIs(t, ers[0], event.ErrListenerNotFound)
+	assert.Equal(t, 1, dlq.Len())
+}
+
+func TestFileDeadLetters_restart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	dlq := event.NewFileDeadLetters(path)
+
+	var audited, mailed int
+	audit := event.ListenerFunc(func(e event.IEvent) error {
+		audited++
+		return nil
+	})
+	mailer := event.ListenerFunc(func(e event.IEvent) error {
+		// the letter is kept in the file until redelivered
+		dls, err := event.NewFileDeadLetters(path).Pending()
+		assert.NoError(t, err)
+		assert.Len(t, dls, 2)
+		mailed++
+		return nil
+	})
+
+	em := event.NewManager("test", event.WithDeadLetter(dlq), event.WithContinueOnError)
+	em.ListenItem("user.created", &event.ListenerItem{Name: "audit", Listener: audit})
+	em.ListenItem("user.created", &event.ListenerItem{Name: "mailer", Listener: event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	})})
+	em.Listen("user.created", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+	_, _ = em.Publish("user.created", event.M{"name": "inhere"})
+	assert.Equal(t, 1, audited)
+
+	// restart, the listeners are registered in another order
+	em = event.NewManager("test", event.WithDeadLetter(dlq))
+	em.ListenItem("user.created", &event.ListenerItem{Name: "mailer", Listener: mailer})
+	em.ListenItem("user.created", &event.ListenerItem{Name: "audit", Listener: audit})
+	em.Listen("user.created", event.ListenerFunc(emptyListener))
+
+	ers := em.RepublishDeadLetters(context.Background(), event.NewFileDeadLetters(path))
+	assert.Len(t, ers, 1)
+	assert.ErrorIs(t, ers[0], event.ErrListenerNotFound)
+	assert.Equal(t, 1, audited)
+	assert.Equal(t, 1, mailed)
+
+	// the letter of the unnamed listener is kept
+	dls, err := dlq.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, dls, 1)
+	assert.Equal(t, "", dls[0].ListenerName)
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordListener record the handled event "n" values
+type recordListener struct {
+	got []interface{}
+	err error
+}
+
+func (l *recordListener) Handle(e event.IEvent) error {
+	l.got = append(l.got, e.Get("n"))
+	return l.err
+}
+
+func TestDebounce(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	rl := &recordListener{}
+	d := event.Debounce(rl, time.Second)
+	d.Clock = clock
+
+	em := event.NewManager("test")
+	em.Listen("config.changed", d, event.High)
+
+	for i := 0; i < 5; i++ {
+		_, _ = em.Publish("config.changed", event.M{"n": i})
+		clock.Advance(500 * time.Millisecond)
+	}
+	assert.Empty(t, rl.got)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, []interface{}{4}, rl.got)
+
+	// nothing pending
+	clock.Advance(time.Second)
+	assert.NoError(t, d.Flush())
+	assert.Len(t, rl.got, 1)
+}
+
+func TestThrottle(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	rl := &recordListener{}
+	th := event.Throttle(rl, time.Second)
+	th.Clock = clock
+
+	// leading is called immediately
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 1})))
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 2})))
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 3})))
+	assert.Equal(t, []interface{}{1}, rl.got)
+
+	// trailing at the interval end
+	clock.Advance(time.Second)
+	assert.Equal(t, []interface{}{1, 3}, rl.got)
+
+	// the interval is extended by the trailing
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 4})))
+	assert.Equal(t, []interface{}{1, 3}, rl.got)
+	clock.Advance(time.Second)
+	assert.Equal(t, []interface{}{1, 3, 4}, rl.got)
+
+	// idle, the next is leading
+	clock.Advance(time.Second)
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 5})))
+	assert.Equal(t, []interface{}{1, 3, 4, 5}, rl.got)
+}
+
+func TestCoalesce(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	rl := &recordListener{}
+	c := event.Coalesce(rl, time.Second, func(prev, next event.IEvent) event.IEvent {
+		next.Set("n", prev.Get("n").(int)+next.Get("n").(int))
+		return next
+	})
+	c.Clock = clock
+
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, c.Handle(event.NewBasicEvent("e1", event.M{"n": i})))
+	}
+	clock.Advance(time.Second)
+	assert.Equal(t, []interface{}{10}, rl.got)
+
+	assert.NoError(t, c.Handle(event.NewBasicEvent("e1", event.M{"n": 5})))
+	clock.Advance(time.Second)
+	assert.Equal(t, []interface{}{10, 5}, rl.got)
+
+	assert.Panics(t, func() {
+		event.Coalesce(rl, time.Second, nil)
+	})
+}
+
+func TestDecorator_errorHandler(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	rl := &recordListener{err: errNotify}
+	d := event.Debounce(rl, time.Second)
+	d.Clock = clock
+
+	var errs []error
+	d.ErrorHandler = func(e event.IEvent, err error) {
+		errs = append(errs, err)
+	}
+
+	assert.NoError(t, d.Handle(event.NewBasicEvent("e1", nil)))
+	clock.Advance(time.Second)
+	assert.Equal(t, []error{errNotify}, errs)
+}
+
+func TestManager_Close_flush(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test")
+
+	rl1 := &recordListener{}
+	d := event.Debounce(rl1, time.Minute)
+	d.Clock = clock
+	em.Listen("config.changed", d)
+
+	rl2 := &recordListener{err: errNotify}
+	th := event.Throttle(rl2, time.Minute)
+	th.Clock = clock
+	sub := em.Listen("config.*", th)
+
+	_, _ = em.Publish("config.changed", event.M{"n": 1})
+	_, _ = em.Publish("config.changed", event.M{"n": 2})
+	assert.Empty(t, rl1.got)
+	assert.Equal(t, []interface{}{1}, rl2.got)
+
+	// the trailing events are flushed on close
+	err := em.Close()
+	assert.ErrorIs(t, err, errNotify)
+	var le *event.ListenerError
+	assert.ErrorAs(t, err, &le)
+	assert.Equal(t, sub.ID(), le.ListenerID)
+
+	assert.Equal(t, []interface{}{2}, rl1.got)
+	assert.Equal(t, []interface{}{1, 2}, rl2.got)
+
+	// the stopped timers will not call again
+	clock.Advance(time.Minute)
+	assert.Equal(t, []interface{}{2}, rl1.got)
+	assert.Equal(t, []interface{}{1, 2}, rl2.got)
+}
+
+func TestManager_Close_flushWrapped(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test")
+
+	// removed after the first call, the pending event is kept by the debouncer
+	rl1 := &recordListener{}
+	d1 := event.Debounce(rl1, time.Minute)
+	d1.Clock = clock
+	em.ListenOnce("evt", d1)
+
+	// not removed yet
+	rl2 := &recordListener{}
+	d2 := event.Debounce(rl2, time.Minute)
+	d2.Clock = clock
+	em.ListenTimes("evt", 2, d2)
+
+	_, _ = em.Publish("evt", event.M{"n": 1})
+	assert.Equal(t, 1, em.ListenersCount("evt"))
+	assert.Empty(t, rl1.got)
+	assert.Empty(t, rl2.got)
+
+	assert.NoError(t, em.Close())
+	assert.Equal(t, []interface{}{1}, rl1.got)
+	assert.Equal(t, []interface{}{1}, rl2.got)
+}
+
+func TestThrottle_Flush(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	rl := &recordListener{}
+	th := event.Throttle(rl, time.Second)
+	th.Clock = clock
+
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 1})))
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 2})))
+	assert.NoError(t, th.Flush())
+	assert.Equal(t, []interface{}{1, 2}, rl.got)
+
+	// a new interval after flush
+	assert.NoError(t, th.Handle(event.NewBasicEvent("e1", event.M{"n": 3})))
+	assert.Equal(t, []interface{}{1, 2, 3}, rl.got)
+}
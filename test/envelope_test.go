@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewID(t *testing.T) {
+	ids := make(map[string]bool)
+	last := ""
+	for i := 0; i < 1000; i++ {
+		id := event.NewID()
+		assert.Len(t, id, 26)
+		assert.False(t, ids[id])
+		ids[id] = true
+
+		// sortable by the time, the first 10 chars is the timestamp.
+		assert.True(t, last == "" || last[:10] <= id[:10])
+		last = id
+	}
+}
+
+func TestBasicEvent_envelope(t *testing.T) {
+	e := event.NewBasicEvent("evt", nil)
+	assert.Equal(t, "", e.ID())
+	assert.True(t, e.Time().IsZero())
+	assert.Nil(t, e.Meta())
+
+	now := time.Now()
+	e.SetID("id1").SetTime(now).SetSource("app").SetMeta("trace", "abc")
+	assert.Equal(t, "id1", e.ID())
+	assert.Equal(t, now, e.Time())
+	assert.Equal(t, "app", e.Source())
+	assert.Equal(t, "abc", e.GetMeta("trace"))
+	assert.Nil(t, e.Get("trace")) // meta is separate from the data
+
+	child := event.NewBasicEvent("child", nil).CausedBy(e)
+	assert.Equal(t, "id1", child.CausationID())
+	assert.Equal(t, "id1", child.CorrelationID())
+
+	e.SetCorrelationID("root")
+	child.CausedBy(e)
+	assert.Equal(t, "root", child.CorrelationID())
+
+	var _ event.IEnvelope = e
+}
+
+func TestManager_stampEnvelope(t *testing.T) {
+	clock := event.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	em := event.NewManager("orders", event.WithClock(clock))
+	em.Listen("evt", event.ListenerFunc(emptyListener))
+
+	err, e := em.Publish("evt", nil)
+	assert.NoError(t, err)
+	env := e.(event.IEnvelope)
+	assert.Len(t, env.ID(), 26)
+	assert.Equal(t, clock.Now(), env.Time())
+	assert.Equal(t, "orders", env.Source())
+
+	_, e2 := em.Publish("evt", nil)
+	assert.NotEqual(t, env.ID(), e2.(event.IEnvelope).ID())
+
+	// keep the user set fields
+	ce := event.NewBasicEvent("evt", nil)
+	ce.SetID("my-id").SetSource("other")
+	assert.NoError(t, em.AwaitPublish(ce))
+	assert.Equal(t, "my-id", ce.ID())
+	assert.Equal(t, "other", ce.Source())
+	assert.Equal(t, clock.Now(), ce.Time())
+}
+
+func TestManager_stampEnvelope_addEvent(t *testing.T) {
+	clock := event.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	em := event.NewManager("orders", event.WithClock(clock))
+	em.Listen("evt", event.ListenerFunc(emptyListener))
+	em.AddEvent(event.NewBasicEvent("evt", nil))
+
+	_, e := em.Publish("evt", nil)
+	env := e.(event.IEnvelope)
+	id1, t1 := env.ID(), env.Time()
+	assert.Len(t, id1, 26)
+	assert.Equal(t, clock.Now(), t1)
+
+	clock.Advance(time.Second)
+	_, e = em.Publish("evt", nil)
+	env = e.(event.IEnvelope)
+	assert.NotEqual(t, id1, env.ID())
+	assert.Equal(t, t1.Add(time.Second), env.Time())
+	assert.Equal(t, "orders", env.Source())
+}
+
+func TestManager_stampEnvelope_async(t *testing.T) {
+	em := event.NewManager("test", event.WithConsumerNum(1))
+	defer em.Close()
+
+	got := make(chan event.IEvent, 1)
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		got <- e
+		return nil
+	}))
+
+	e := event.NewBasicEvent("evt", nil)
+	before := time.Now()
+	assert.NoError(t, em.AsyncPublish(e))
+	// stamped on enqueue
+	assert.NotEmpty(t, e.ID())
+	assert.False(t, e.Time().Before(before.Truncate(time.Second)))
+
+	select {
+	case ge := <-got:
+		assert.Equal(t, e.ID(), ge.(event.IEnvelope).ID())
+	case <-time.After(time.Second):
+		t.Fatal("not received")
+	}
+}
+
+func TestWithCause(t *testing.T) {
+	em := event.NewManager("test")
+
+	var child event.IEnvelope
+	em.Listen("order.created", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		_, _ = em.PublishContext(event.WithCause(ctx, e), "stock.reserved", nil)
+		return nil
+	}))
+	em.Listen("stock.reserved", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		_, _ = em.PublishContext(event.WithCause(ctx, e), "mail.sent", nil)
+		return nil
+	}))
+	em.Listen("mail.sent", event.ListenerFunc(func(e event.IEvent) error {
+		child = e.(event.IEnvelope)
+		return nil
+	}))
+
+	_, root := em.Publish("order.created", nil)
+	rootID := root.(event.IEnvelope).ID()
+
+	assert.NotNil(t, child)
+	assert.Equal(t, rootID, child.CorrelationID())
+	assert.NotEqual(t, rootID, child.CausationID())
+	assert.NotEmpty(t, child.CausationID())
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+var errNotify = errors.New("notify failed")
+
+type testCodeError struct {
+	code int
+}
+
+func (e *testCodeError) Error() string {
+	return fmt.Sprintf("code: %d", e.code)
+}
+
+func TestManager_ContinueOnError(t *testing.T) {
+	em := event.NewManager("test", event.WithContinueOnError)
+
+	var called []string
+	s1 := em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l1")
+		return errNotify
+	}), event.High)
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l2")
+		return nil
+	}))
+	s3 := em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l3")
+		return &testCodeError{code: 500}
+	}), event.Low)
+
+	err, _ := em.Publish("e1", nil)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"l1", "l2", "l3"}, called)
+
+	var les *event.ListenerErrors
+	assert.True(t, errors.As(err, &les))
+	assert.Len(t, les.Errors, 2)
+	assert.Equal(t, s1.ID(), les.Errors[0].ListenerID)
+	assert.Equal(t, s3.ID(), les.Errors[1].ListenerID)
+	assert.Equal(t, "e1", les.Errors[1].EventName)
+	assert.Contains(t, err.Error(), "2 listener(s) failed")
+	assert.Len(t, les.Unwrap(), 2)
+
+	// errors.Is and errors.As on each listener error
+	assert.ErrorIs(t, err, errNotify)
+	var ce *testCodeError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, 500, ce.code)
+	var le *event.ListenerError
+	assert.True(t, errors.As(err, &le))
+	assert.Equal(t, s1.ID(), le.ListenerID)
+}
+
+func TestManager_ContinueOnError_abort(t *testing.T) {
+	em := event.NewManager("test", event.WithContinueOnError)
+
+	var called []string
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l1")
+		e.Abort(true)
+		return errNotify
+	}), event.High)
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called = append(called, "l2")
+		return nil
+	}))
+
+	err, _ := em.Publish("e1", nil)
+	assert.ErrorIs(t, err, errNotify)
+	assert.Equal(t, []string{"l1"}, called)
+
+	// no error
+	em.RemoveListeners("e1")
+	em.Listen("e1", event.ListenerFunc(emptyListener))
+	err, _ = em.Publish("e1", nil)
+	assert.NoError(t, err)
+}
+
+func TestManager_ContinueOnError_ctx(t *testing.T) {
+	em := event.NewManager("test", event.WithContinueOnError)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var called int
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called++
+		cancel()
+		return errNotify
+	}))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		called++
+		return nil
+	}))
+
+	err, _ := em.PublishContext(ctx, "e1", nil)
+	assert.ErrorIs(t, err, errNotify)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, called)
+}
+
+func TestManager_BatchPublish_ContinueOnError(t *testing.T) {
+	em := event.NewManager("test", event.WithContinueOnError)
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+	em.Listen("e2", event.ListenerFunc(emptyListener))
+	em.Listen("e3", event.ListenerFunc(func(e event.IEvent) error {
+		return &testCodeError{code: 400}
+	}))
+
+	ers := em.BatchPublish("e1", "e2", "e3")
+	assert.Len(t, ers, 2)
+
+	var les *event.ListenerErrors
+	assert.True(t, errors.As(ers[0], &les))
+	assert.Len(t, les.Errors, 2)
+	assert.Equal(t, "e1", les.Errors[0].EventName)
+	assert.True(t, errors.As(ers[1], &les))
+	assert.Equal(t, "e3", les.Errors[0].EventName)
+}
@@ -273,15 +273,21 @@ func TestListenGroupEvent(t *testing.T) {
 
 func TestManager_AsyncPublish(t *testing.T) {
 	em := event.NewManager("test")
+	done := make(chan struct{})
 	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
 		assert.Equal(t, map[string]interface{}{"k": "v"}, e.Data())
 		e.Set("nk", "nv")
+		close(done)
 		return nil
 	}))
 
 	e1 := event.NewBasicEvent("e1", event.M{"k": "v"})
 	em.AsyncPublish(e1)
-	time.Sleep(time.Second / 10)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the async event is not handled")
+	}
 	assert.Equal(t, "nv", e1.Get("nk"))
 
 	var wg sync.WaitGroup
@@ -0,0 +1,288 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_Attach(t *testing.T) {
+	dir := t.TempDir()
+	j, err := event.OpenJournal(dir, event.WithSyncPolicy(event.JournalSyncAlways, 0))
+	assert.NoError(t, err)
+
+	em := event.NewManager("test")
+	em.Listen("order.*", event.ListenerFunc(emptyListener))
+	detach := j.Attach(em)
+
+	_, _ = em.Publish("order.created", event.M{"id": "o1"})
+	_, _ = em.Publish("order.paid", event.M{"id": "o1"})
+	assert.Equal(t, uint64(2), j.LastSeq())
+
+	// recorded without listeners
+	_, _ = em.Publish("user.login", event.M{"id": "o1"})
+	assert.Equal(t, uint64(3), j.LastSeq())
+
+	detach()
+	_, _ = em.Publish("order.done", nil)
+	assert.Equal(t, uint64(3), j.LastSeq())
+
+	var names []string
+	err = j.Scan(func(en *event.JournalEntry) error {
+		names = append(names, en.Event.Name())
+		assert.Equal(t, "o1", en.Event.Get("id"))
+		assert.NotEmpty(t, en.Event.(event.IEnvelope).ID())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"order.created", "order.paid", "user.login"}, names)
+
+	// reopen
+	assert.NoError(t, j.Close())
+	_, err = j.Append(event.NewBasicEvent("evt", nil))
+	assert.ErrorIs(t, err, event.ErrJournalClosed)
+
+	j, err = event.OpenJournal(dir)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, uint64(3), j.LastSeq())
+
+	seq, err := j.Append(event.NewBasicEvent("evt", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), seq)
+}
+
+func TestJournal_hookError(t *testing.T) {
+	em := event.NewManager("test")
+	var calls int
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}))
+
+	errHook := errors.New("hook error")
+	remove := em.OnPublish(func(ctx context.Context, e event.IEvent) error {
+		return errHook
+	})
+
+	err, _ := em.Publish("evt", nil)
+	assert.ErrorIs(t, err, errHook)
+	assert.Equal(t, 0, calls)
+
+	remove()
+	err, _ = em.Publish("evt", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestJournal_segments(t *testing.T) {
+	dir := t.TempDir()
+	j, err := event.OpenJournal(dir, event.WithSegmentSize(200))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = j.Append(event.NewBasicEvent("evt", event.M{"i": i}))
+		assert.NoError(t, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.journal"))
+	assert.Greater(t, len(files), 1)
+
+	var seqs []uint64
+	assert.NoError(t, j.Scan(func(en *event.JournalEntry) error {
+		seqs = append(seqs, en.Seq)
+		assert.Equal(t, float64(en.Seq-1), en.Event.Get("i"))
+		return nil
+	}))
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, seqs)
+	assert.NoError(t, j.Close())
+
+	// reopen in the last segment
+	j, err = event.OpenJournal(dir, event.WithSegmentSize(200))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), j.LastSeq())
+	assert.NoError(t, j.Close())
+}
+
+func TestJournal_tornWrite(t *testing.T) {
+	dir := t.TempDir()
+	j, err := event.OpenJournal(dir, event.WithJournalCodec(&event.MsgpackCodec{}))
+	assert.NoError(t, err)
+	_, _ = j.Append(event.NewBasicEvent("evt1", nil))
+	_, _ = j.Append(event.NewBasicEvent("evt2", nil))
+	assert.NoError(t, j.Close())
+
+	// simulate a crash on write: an incomplete record
+	files, _ := filepath.Glob(filepath.Join(dir, "*.journal"))
+	assert.Len(t, files, 1)
+	info, _ := os.Stat(files[0])
+	assert.NoError(t, os.Truncate(files[0], info.Size()-3))
+
+	j, err = event.OpenJournal(dir, event.WithJournalCodec(&event.MsgpackCodec{}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), j.LastSeq())
+
+	seq, err := j.Append(event.NewBasicEvent("evt3", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), seq)
+
+	var names []string
+	assert.NoError(t, j.Scan(func(en *event.JournalEntry) error {
+		names = append(names, en.Event.Name())
+		return nil
+	}))
+	assert.Equal(t, []string{"evt1", "evt3"}, names)
+	assert.NoError(t, j.Close())
+
+	// corrupt the CRC of the first record in a non-last segment
+	bs, _ := os.ReadFile(files[0])
+	bs[9] ^= 0xff
+	assert.NoError(t, os.WriteFile(files[0], bs, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000099.journal"), nil, 0644))
+
+	j, err = event.OpenJournal(dir, event.WithJournalCodec(&event.MsgpackCodec{}))
+	assert.NoError(t, err)
+	defer j.Close()
+	err = j.Scan(func(en *event.JournalEntry) error { return nil })
+	assert.ErrorIs(t, err, event.ErrJournalCorrupt)
+}
+
+func TestJournal_corruptMiddle(t *testing.T) {
+	dir := t.TempDir()
+	j, err := event.OpenJournal(dir)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _ = j.Append(event.NewBasicEvent("evt", event.M{"i": i}))
+	}
+	assert.NoError(t, j.Close())
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.journal"))
+	bs, _ := os.ReadFile(files[0])
+
+	// the CRC mismatch of the last record is an incomplete write
+	last := append([]byte(nil), bs...)
+	last[len(last)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(files[0], last, 0644))
+	j, err = event.OpenJournal(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), j.LastSeq())
+	assert.NoError(t, j.Close())
+
+	// a zero filled tail is an incomplete write
+	zero := append(append([]byte(nil), bs...), make([]byte, 64)...)
+	assert.NoError(t, os.WriteFile(files[0], zero, 0644))
+	j, err = event.OpenJournal(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), j.LastSeq())
+	assert.NoError(t, j.Close())
+
+	// the corrupt record in the middle is not truncated
+	bs[journalPayloadOffset] ^= 0xff
+	assert.NoError(t, os.WriteFile(files[0], bs, 0644))
+	_, err = event.OpenJournal(dir)
+	assert.ErrorIs(t, err, event.ErrJournalCorrupt)
+
+	info, _ := os.Stat(files[0])
+	assert.Equal(t, int64(len(bs)), info.Size())
+}
+
+// the offset of the first payload byte after the seq and time
+const journalPayloadOffset = 8 + 16
+
+func TestJournal_Replay(t *testing.T) {
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	j, err := event.OpenJournal(t.TempDir())
+	assert.NoError(t, err)
+	defer j.Close()
+
+	for i, name := range []string{"user.login", "order.created", "user.logout", "order.paid"} {
+		e := event.NewBasicEvent(name, nil)
+		e.SetID(event.NewID()).SetTime(base.Add(time.Duration(i) * time.Second))
+		_, err = j.Append(e)
+		assert.NoError(t, err)
+	}
+
+	em := event.NewManager("test")
+	var names []string
+	var replayed bool
+	em.Listen("*", event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+		names = append(names, e.Name())
+		replayed = event.IsReplay(ctx)
+		return nil
+	}))
+	// the replayed events are not recorded again
+	j.Attach(em)
+
+	n, err := j.Replay(context.Background(), em, time.Time{}, time.Time{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.True(t, replayed)
+	assert.Equal(t, []string{"user.login", "order.created", "user.logout", "order.paid"}, names)
+	assert.Equal(t, uint64(4), j.LastSeq())
+
+	// time range and filter
+	names = nil
+	n, err = j.Replay(context.Background(), em, base.Add(time.Second), base.Add(3*time.Second), func(en *event.JournalEntry) bool {
+		return en.Event.Name() != "user.logout"
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"order.created"}, names)
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = j.Replay(ctx, em, time.Time{}, time.Time{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// live publish is recorded
+	_, _ = em.Publish("user.login", nil)
+	assert.Equal(t, uint64(5), j.LastSeq())
+}
+
+func TestJournal_Replay_speed(t *testing.T) {
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	j, err := event.OpenJournal(t.TempDir())
+	assert.NoError(t, err)
+	defer j.Close()
+
+	for i := 0; i < 3; i++ {
+		e := event.NewBasicEvent("evt", nil)
+		e.SetID(event.NewID()).SetTime(base.Add(time.Duration(i) * 10 * time.Second))
+		_, _ = j.Append(e)
+	}
+
+	clock := event.NewFakeClock(base)
+	em := event.NewManager("test", event.WithClock(clock))
+
+	calls := make(chan struct{}, 3)
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		calls <- struct{}{}
+		return nil
+	}))
+
+	done := make(chan int)
+	go func() {
+		n, _ := j.Replay(context.Background(), em, time.Time{}, time.Time{}, nil, event.WithReplaySpeed(2))
+		done <- n
+	}()
+
+	<-calls
+	// wait 10s / 2
+	clock.BlockUntil(1)
+	clock.Advance(4 * time.Second)
+	assert.Len(t, calls, 0)
+	clock.Advance(time.Second)
+	<-calls
+
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	<-calls
+	assert.Equal(t, 3, <-done)
+}
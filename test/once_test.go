@@ -0,0 +1,68 @@
+package test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_ListenOnce(t *testing.T) {
+	em := event.NewManager("test")
+
+	var calls int
+	em.ListenOnce("app.*", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}), event.High)
+	em.Listen("app.start", event.ListenerFunc(emptyListener))
+
+	_, _ = em.Publish("app.start", nil)
+	_, _ = em.Publish("app.start", nil)
+	assert.Equal(t, 1, calls)
+	assert.False(t, em.HasListeners("app.*"))
+	assert.True(t, em.HasListeners("app.start"))
+
+	// unsubscribe before fired
+	sub := em.ListenOnce("app.stop", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}))
+	sub.Unsubscribe()
+	_, _ = em.Publish("app.stop", nil)
+	assert.Equal(t, 1, calls)
+
+	assert.Panics(t, func() {
+		em.ListenOnce("app.stop", nil)
+	})
+	assert.Panics(t, func() {
+		em.ListenTimes("app.stop", 0, event.ListenerFunc(emptyListener))
+	})
+}
+
+func TestManager_ListenTimes_concurrent(t *testing.T) {
+	em := event.NewManager("test")
+
+	var calls int64
+	em.ListenTimes("e1", 10, event.ListenerFunc(func(e event.IEvent) error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _ = em.Publish("e1", nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), atomic.LoadInt64(&calls))
+	assert.False(t, em.HasListeners("e1"))
+}
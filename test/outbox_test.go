@@ -0,0 +1,241 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTx_Commit(t *testing.T) {
+	em := event.NewManager("test")
+	var names []string
+	em.Listen("order.*", event.ListenerFunc(func(e event.IEvent) error {
+		names = append(names, e.Name())
+		return nil
+	}))
+
+	tx := em.Begin()
+	err, e := tx.Publish("order.created", event.M{"id": 1})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, e.(event.IEnvelope).ID())
+	assert.NoError(t, tx.Add(event.NewBasicEvent("order.paid", nil)))
+	assert.Equal(t, 2, tx.Len())
+	assert.Empty(t, names)
+
+	assert.NoError(t, tx.Commit(context.Background()))
+	assert.Equal(t, []string{"order.created", "order.paid"}, names)
+
+	// done
+	assert.ErrorIs(t, tx.Commit(context.Background()), event.ErrTxDone)
+	err, _ = tx.Publish("order.created", nil)
+	assert.ErrorIs(t, err, event.ErrTxDone)
+}
+
+func TestTx_Rollback(t *testing.T) {
+	em := event.NewManager("test")
+	var calls int
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}))
+
+	tx := em.Begin()
+	_, _ = tx.Publish("evt", nil)
+	tx.Rollback()
+	assert.ErrorIs(t, tx.Commit(context.Background()), event.ErrTxDone)
+	assert.Equal(t, 0, calls)
+
+	// transaction
+	errWork := errors.New("work failed")
+	err := em.Transaction(context.Background(), func(tx *event.Tx) error {
+		_, _ = tx.Publish("evt", nil)
+		return errWork
+	})
+	assert.ErrorIs(t, err, errWork)
+	assert.Equal(t, 0, calls)
+
+	err = em.Transaction(context.Background(), func(tx *event.Tx) error {
+		_, _ = tx.Publish("evt", nil)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func testOutbox(t *testing.T, newStore func() event.OutboxStore) {
+	store := newStore()
+	em := event.NewManager("test", event.WithOutbox(store))
+
+	failing := true
+	var names []string
+	em.Listen("order.*", event.ListenerFunc(func(e event.IEvent) error {
+		if failing && e.Name() == "order.paid" {
+			return errNotify
+		}
+		names = append(names, e.Name())
+		return nil
+	}))
+
+	tx := em.Begin()
+	_, _ = tx.Publish("order.created", nil)
+	_, _ = tx.Publish("order.paid", nil)
+	_, _ = tx.Publish("order.shipped", nil)
+	assert.ErrorIs(t, tx.Commit(context.Background()), errNotify)
+	assert.Equal(t, []string{"order.created"}, names)
+
+	pending, err := store.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "order.paid", pending[0].Event.Name())
+
+	// simulate restart: a new manager with the reopened store
+	store = newStore()
+	em2 := event.NewManager("test", event.WithOutbox(store))
+	em2.Listen("order.*", event.ListenerFunc(func(e event.IEvent) error {
+		names = append(names, e.Name())
+		return nil
+	}))
+
+	n, err := em2.RelayOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"order.created", "order.paid", "order.shipped"}, names)
+
+	pending, _ = store.Pending()
+	assert.Empty(t, pending)
+	n, _ = em2.RelayOutbox(context.Background())
+	assert.Equal(t, 0, n)
+}
+
+func TestMemoryOutbox(t *testing.T) {
+	store := event.NewMemoryOutbox()
+	testOutbox(t, func() event.OutboxStore { return store })
+}
+
+func TestFileOutbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	testOutbox(t, func() event.OutboxStore {
+		store, err := event.NewFileOutbox(path, &event.MsgpackCodec{})
+		assert.NoError(t, err)
+		return store
+	})
+}
+
+func TestRelayOutbox_error(t *testing.T) {
+	store := event.NewMemoryOutbox()
+	em := event.NewManager("test", event.WithOutbox(store))
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+
+	tx := em.Begin()
+	_, _ = tx.Publish("evt", nil)
+	assert.Error(t, tx.Commit(context.Background()))
+
+	n, err := em.RelayOutbox(context.Background())
+	assert.Equal(t, 0, n)
+	assert.ErrorIs(t, err, errNotify)
+
+	pending, _ := store.Pending()
+	assert.Len(t, pending, 1)
+
+	// no outbox
+	n, err = event.NewManager("test").RelayOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestFileOutbox_tornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	// an incomplete line left by a crash
+	assert.NoError(t, os.WriteFile(path, []byte(`{"op":"add","id":"x","ev`), 0644))
+
+	store, err := event.NewFileOutbox(path, nil)
+	assert.NoError(t, err)
+	pending, _ := store.Pending()
+	assert.Empty(t, pending)
+
+	em := event.NewManager("test", event.WithOutbox(store))
+	em.Listen("evt", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+	tx := em.Begin()
+	_, _ = tx.Publish("evt", nil)
+	assert.Error(t, tx.Commit(context.Background()))
+
+	// reopen, the entry is kept
+	store, err = event.NewFileOutbox(path, nil)
+	assert.NoError(t, err)
+	pending, _ = store.Pending()
+	assert.Len(t, pending, 1)
+
+	// a bad line in the middle is corrupt
+	bs, _ := os.ReadFile(path)
+	assert.NoError(t, os.WriteFile(path, append([]byte("bad line\n"), bs...), 0644))
+	_, err = event.NewFileOutbox(path, nil)
+	assert.ErrorIs(t, err, event.ErrOutboxCorrupt)
+}
+
+func TestFileOutbox_compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	o, err := event.NewFileOutbox(path, nil)
+	assert.NoError(t, err)
+
+	entries := make([]*event.OutboxEntry, 1100)
+	for i := range entries {
+		entries[i] = &event.OutboxEntry{ID: event.NewID(), Event: event.NewBasicEvent("evt", event.M{"i": i})}
+	}
+	assert.NoError(t, o.Save(entries))
+	before, _ := os.Stat(path)
+
+	// a stuck entry, the others are delivered
+	for _, en := range entries[1:] {
+		assert.NoError(t, o.MarkDelivered(en.ID))
+	}
+
+	after, _ := os.Stat(path)
+	assert.Less(t, after.Size(), before.Size())
+
+	o, err = event.NewFileOutbox(path, nil)
+	assert.NoError(t, err)
+	pending, err := o.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, entries[0].ID, pending[0].ID)
+}
+
+func TestRelayOutbox_inflight(t *testing.T) {
+	em := event.NewManager("test", event.WithOutbox(event.NewMemoryOutbox()))
+
+	var calls int
+	started, release := make(chan struct{}), make(chan struct{})
+	em.Listen("order.created", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		close(started)
+		<-release
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() {
+		done <- em.Transaction(context.Background(), func(tx *event.Tx) error {
+			err, _ := tx.Publish("order.created", nil)
+			return err
+		})
+	}()
+
+	// the entry delivering by the Commit is skipped
+	<-started
+	n, err := em.RelayOutbox(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,288 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionedLog_Append(t *testing.T) {
+	log := event.NewPartitionedLog(4, "user")
+	assert.Equal(t, 4, log.Partitions())
+
+	// the same key in the same partition, keep the order
+	var rs []*event.LogRecord
+	for i := 0; i < 3; i++ {
+		rs = append(rs, log.Append(event.NewBasicEvent("evt", event.M{"user": "u1", "i": i})))
+	}
+	p := log.PartitionFor("u1")
+	for i, r := range rs {
+		assert.Equal(t, p, r.Partition)
+		assert.Equal(t, int64(i), r.Offset)
+		assert.Equal(t, "u1", r.Key)
+	}
+
+	got := log.Read(p, 1, 1)
+	assert.Len(t, got, 1)
+	assert.Equal(t, 1, got[0].Event.Get("i"))
+	assert.Len(t, log.Read(p, 0, 0), 3)
+	assert.Nil(t, log.Read(p, 3, 0))
+	assert.Nil(t, log.Read(10, 0, 0))
+	assert.Equal(t, int64(3), log.EndOffset(p))
+
+	// no key: round-robin
+	r1 := log.Append(event.NewBasicEvent("evt", nil))
+	r2 := log.Append(event.NewBasicEvent("evt", nil))
+	assert.NotEqual(t, r1.Partition, r2.Partition)
+
+	assert.Panics(t, func() {
+		event.NewPartitionedLog(0, "user")
+	})
+}
+
+func TestPartitionedLog_Attach(t *testing.T) {
+	em := event.NewManager("test")
+	log := event.NewPartitionedLog(2, "id")
+	detach := log.Attach(em, "order.*")
+
+	_, _ = em.Publish("order.created", event.M{"id": 1})
+	_, _ = em.Publish("user.created", event.M{"id": 1})
+	p := log.PartitionFor("1")
+	assert.Equal(t, int64(1), log.EndOffset(p)+log.EndOffset(1-p))
+
+	detach()
+	_, _ = em.Publish("order.paid", event.M{"id": 1})
+	assert.Equal(t, int64(1), log.EndOffset(p))
+}
+
+func TestPartitionedLog_Attach_replay(t *testing.T) {
+	j, err := event.OpenJournal(t.TempDir())
+	assert.NoError(t, err)
+	defer j.Close()
+
+	em := event.NewManager("test")
+	j.Attach(em)
+	log := event.NewPartitionedLog(1, "id")
+	log.Attach(em, "order.*")
+
+	_, _ = em.Publish("order.created", event.M{"id": 1})
+	assert.Equal(t, int64(1), log.EndOffset(0))
+
+	// the replayed events are not appended again
+	n, err := j.Replay(context.Background(), em, time.Time{}, time.Time{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(1), log.EndOffset(0))
+}
+
+func TestPartitionedLog_Truncate(t *testing.T) {
+	log := event.NewPartitionedLog(1, "id")
+	for i := 0; i < 5; i++ {
+		log.Append(event.NewBasicEvent("evt", event.M{"i": i}))
+	}
+
+	g := log.Group("g1")
+	c := g.Join("c1")
+	rs, err := c.Poll(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Commit(rs[1]))
+
+	assert.Equal(t, 0, log.Truncate(0, 0))
+	assert.Equal(t, 0, log.Truncate(3, 1))
+	assert.Equal(t, 1, log.Truncate(0, 1))
+	assert.Equal(t, int64(1), log.StartOffset(0))
+	assert.Equal(t, int64(5), log.EndOffset(0))
+
+	// the offsets are not changed
+	got := log.Read(0, 0, 1)
+	assert.Len(t, got, 1)
+	assert.Equal(t, int64(1), got[0].Offset)
+	assert.Equal(t, 1, got[0].Event.Get("i"))
+
+	// drop the committed records
+	assert.Equal(t, 1, log.TruncateCommitted())
+	assert.Equal(t, int64(2), log.StartOffset(0))
+	assert.Equal(t, int64(3), g.Lag())
+
+	// the consumer behind the start offset skip the dropped records
+	assert.NoError(t, c.Seek(0, 0))
+	assert.Equal(t, 3, log.Truncate(0, 10))
+	assert.Equal(t, int64(0), g.Lag())
+	log.Append(event.NewBasicEvent("evt", event.M{"i": 5}))
+
+	rs, err = c.Poll(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, rs, 1)
+	assert.Equal(t, int64(5), rs[0].Offset)
+}
+
+func TestConsumerGroup_rebalance(t *testing.T) {
+	log := event.NewPartitionedLog(4, "key")
+	g := log.Group("workers")
+	assert.Same(t, g, log.Group("workers"))
+	assert.Equal(t, "workers", g.Name())
+
+	c1 := g.Join("c1")
+	assert.Equal(t, []int{0, 1, 2, 3}, c1.Assignment())
+	assert.Same(t, c1, g.Join("c1"))
+
+	c2 := g.Join("c2")
+	assert.Equal(t, []int{0, 2}, c1.Assignment())
+	assert.Equal(t, []int{1, 3}, c2.Assignment())
+	assert.Equal(t, []string{"c1", "c2"}, g.Members())
+	assert.Equal(t, 2, g.Generation())
+
+	c1.Leave()
+	c1.Leave()
+	assert.Equal(t, []int{0, 1, 2, 3}, c2.Assignment())
+	assert.Empty(t, c1.Assignment())
+	_, err := c1.Poll(context.Background(), 0)
+	assert.ErrorIs(t, err, event.ErrConsumerClosed)
+}
+
+func TestConsumer_atLeastOnce(t *testing.T) {
+	log := event.NewPartitionedLog(2, "key")
+	for i := 0; i < 4; i++ {
+		log.Append(event.NewBasicEvent("evt", event.M{"i": i}))
+	}
+
+	g := log.Group("g1")
+	c1 := g.Join("c1")
+	rs, err := c1.Poll(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, rs, 4)
+	assert.Equal(t, int64(4), g.Lag())
+
+	// commit the first record of each partition
+	for _, r := range rs {
+		if r.Offset == 0 {
+			assert.NoError(t, c1.Commit(r))
+		}
+	}
+	assert.Equal(t, int64(2), g.Lag())
+	assert.Equal(t, int64(1), g.Committed(0))
+
+	// rebalance, the not committed records are delivered again
+	c2 := g.Join("c2")
+	for _, r := range rs {
+		if r.Partition == 1 {
+			assert.ErrorIs(t, c1.Commit(r), event.ErrNotAssigned)
+		}
+	}
+
+	rs1, _ := c1.Poll(context.Background(), 0)
+	rs2, _ := c2.Poll(context.Background(), 0)
+	assert.Len(t, rs1, 1)
+	assert.Len(t, rs2, 1)
+	assert.Equal(t, int64(1), rs1[0].Offset)
+	assert.Equal(t, int64(1), rs2[0].Offset)
+
+	// other group has its own offsets
+	c3 := log.Group("g2").Join("c3")
+	rs, _ = c3.Poll(context.Background(), 3)
+	assert.Len(t, rs, 3)
+
+	// poll with ctx done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c1.Poll(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConsumer_Run(t *testing.T) {
+	log := event.NewPartitionedLog(3, "key")
+	g := log.Group("g")
+	c := g.Join("c1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	failed := false
+	done := make(chan error)
+	go func() {
+		done <- c.Run(ctx, event.ContextListenerFunc(func(ctx context.Context, e event.IEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if e.Get("i") == 2 && !failed {
+				failed = true
+				return errNotify
+			}
+			key := e.Get("key").(string)
+			got[key] = append(got[key], e.Get("i").(int))
+			return nil
+		}))
+	}()
+
+	for i := 0; i < 6; i++ {
+		log.Append(event.NewBasicEvent("evt", event.M{"key": []string{"a", "b"}[i%2], "i": i}))
+	}
+
+	// the failed record is delivered again on next run
+	assert.ErrorIs(t, <-done, errNotify)
+	go func() {
+		done <- c.Run(ctx, event.ListenerFunc(func(e event.IEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			key := e.Get("key").(string)
+			got[key] = append(got[key], e.Get("i").(int))
+			return nil
+		}))
+	}()
+
+	assert.Eventually(t, func() bool {
+		return g.Lag() == 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{0, 2, 4}, got["a"])
+	assert.Equal(t, []int{1, 3, 5}, got["b"])
+}
+
+func TestConsumer_Run_rebalance(t *testing.T) {
+	log := event.NewPartitionedLog(2, "key")
+	// find a key of each partition
+	keys := make([]string, 2)
+	for i := 0; keys[0] == "" || keys[1] == ""; i++ {
+		key := string(rune('a' + i))
+		keys[log.PartitionFor(key)] = key
+	}
+	for i := 0; i < 6; i++ {
+		log.Append(event.NewBasicEvent("evt", event.M{"key": keys[i%2], "i": i}))
+	}
+
+	g := log.Group("g")
+	c1 := g.Join("c1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []int
+	done := make(chan error)
+	go func() {
+		done <- c1.Run(ctx, event.ListenerFunc(func(e event.IEvent) error {
+			if len(got) == 0 {
+				// the partition 1 is revoked from c1
+				g.Join("c2")
+			}
+			got = append(got, e.Get("i").(int))
+			if len(got) == 3 {
+				cancel()
+			}
+			return nil
+		}))
+	}()
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+	// only the records of the partition 0 are handled by c1
+	assert.Equal(t, []int{0, 2, 4}, got)
+	assert.Equal(t, int64(3), g.Committed(0))
+	assert.Equal(t, int64(0), g.Committed(1))
+}
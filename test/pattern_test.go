@@ -0,0 +1,125 @@
+package test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Listen_patterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*", "a", true},
+		{"*", "a.b.c", true},
+		{"**", "a.b.c", true},
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"a.*", "a", false},
+		{"a.b.*", "a.b.c", true},
+		{"order.*.created", "order.123.created", true},
+		{"order.*.created", "order.created", false},
+		{"order.*.created", "order.1.2.created", false},
+		{"*.created", "user.created", true},
+		{"*.created", "user.deleted", false},
+		{"a.**", "a.b", true},
+		{"a.**", "a.b.c.d", true},
+		{"a.**", "a", false},
+		{"a.**", "b.c", false},
+		{"a.**.z", "a.b.z", true},
+		{"a.**.z", "a.b.c.z", true},
+		{"a.**.z", "a.z", false},
+		{"a.**.z", "a.b.c", false},
+	}
+
+	for _, tt := range tests {
+		em := event.NewManager("test")
+		var called bool
+		em.Listen(tt.pattern, event.ListenerFunc(func(e event.IEvent) error {
+			called = true
+			return nil
+		}))
+
+		err, e := em.Publish(tt.name, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.matched, called, "pattern %q, name %q", tt.pattern, tt.name)
+		assert.Equal(t, tt.matched, e != nil, "pattern %q, name %q", tt.pattern, tt.name)
+	}
+}
+
+func TestManager_Listen_invalidPattern(t *testing.T) {
+	em := event.NewManager("test")
+	for _, pattern := range []string{"a*", "a.b*", "a.***", "a..*", "a.*."} {
+		assert.Panics(t, func() {
+			em.Listen(pattern, event.ListenerFunc(emptyListener))
+		}, pattern)
+	}
+}
+
+func TestManager_Publish_patternOrder(t *testing.T) {
+	em := event.NewManager("test")
+
+	var got []string
+	add := func(pattern string) *event.Subscription {
+		return em.Listen(pattern, event.ListenerFunc(func(e event.IEvent) error {
+			got = append(got, pattern)
+			return nil
+		}))
+	}
+
+	add("*")
+	add("app.**")
+	add("app.*.created")
+	s4 := add("app.user.*")
+	add("app.user.created")
+
+	err, _ := em.Publish("app.user.created", nil)
+	assert.NoError(t, err)
+	// exact first, then patterns, "*" is the last
+	assert.Equal(t, []string{"app.user.created", "app.**", "app.*.created", "app.user.*", "*"}, got)
+
+	got = got[:0]
+	s4.Unsubscribe()
+	em.RemoveListeners("app.**")
+	err, _ = em.Publish("app.user.created", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app.user.created", "app.*.created", "*"}, got)
+}
+
+func TestManager_ListenersFor(t *testing.T) {
+	em := event.NewManager("test")
+	s1 := em.Listen("app.user.created", event.ListenerFunc(emptyListener))
+	s2 := em.Listen("app.**", event.ListenerFunc(emptyListener))
+
+	items := em.ListenersFor("app.user.created")
+	assert.Len(t, items, 2)
+	assert.Equal(t, s1.ID(), items[0].ID)
+	assert.Equal(t, s2.ID(), items[1].ID)
+	assert.Empty(t, em.ListenersFor("other"))
+
+	// the cached result is updated on listeners changed.
+	s3 := em.Listen("*", event.ListenerFunc(emptyListener))
+	items = em.ListenersFor("app.user.created")
+	assert.Len(t, items, 3)
+	assert.Equal(t, s3.ID(), items[2].ID)
+	assert.Len(t, em.ListenersFor("other"), 1)
+
+	s2.Unsubscribe()
+	assert.Len(t, em.ListenersFor("app.user.created"), 2)
+}
+
+func TestManager_ListenersFor_manyNames(t *testing.T) {
+	em := event.NewManager("test")
+	s1 := em.Listen("user.*", event.ListenerFunc(emptyListener))
+
+	// more names than the cache size, the cache is cleared and filled again.
+	for i := 0; i < 10000; i++ {
+		items := em.ListenersFor("user." + strconv.Itoa(i%5000))
+		assert.Len(t, items, 1)
+		assert.Equal(t, s1.ID(), items[0].ID)
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_UnifiedPriority(t *testing.T) {
+	em := event.NewManager("test", event.WithUnifiedPriority)
+
+	var got []string
+	add := func(name, tag string, priority int) {
+		em.Listen(name, event.ListenerFunc(func(e event.IEvent) error {
+			got = append(got, tag)
+			if tag == "abort" {
+				e.Abort(true)
+			}
+			return nil
+		}), priority)
+	}
+
+	add("app.user.login", "exact-low", event.Low)
+	add("app.user.*", "group-normal", event.Normal)
+	add("*", "audit-max", event.Max)
+	add("app.**", "deep-normal", event.Normal)
+	add("app.user.login", "exact-normal", event.Normal)
+
+	err, _ := em.Publish("app.user.login", nil)
+	assert.NoError(t, err)
+	// same priority keep the add order
+	assert.Equal(t, []string{"audit-max", "group-normal", "deep-normal", "exact-normal", "exact-low"}, got)
+
+	// abort stop the whole chain
+	got = got[:0]
+	add("*", "abort", event.High)
+	err, _ = em.Publish("app.user.login", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"audit-max", "abort"}, got)
+}
+
+func TestManager_UnifiedPriority_disabled(t *testing.T) {
+	em := event.NewManager("test")
+
+	var got []string
+	add := func(name, tag string, priority int) {
+		em.Listen(name, event.ListenerFunc(func(e event.IEvent) error {
+			got = append(got, tag)
+			return nil
+		}), priority)
+	}
+
+	add("app.login", "exact-low", event.Low)
+	add("*", "audit-max", event.Max)
+
+	err, _ := em.Publish("app.login", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"exact-low", "audit-max"}, got)
+
+	// can be switched on the fly
+	got = got[:0]
+	em.UnifiedPriority = true
+	err, _ = em.Publish("app.login", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"audit-max", "exact-low"}, got)
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := event.NewRateLimiter(2, 3)
+
+	// burst
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow(now))
+	}
+	assert.False(t, l.Allow(now))
+
+	// 2 tokens per second
+	assert.True(t, l.Allow(now.Add(500*time.Millisecond)))
+	assert.False(t, l.Allow(now.Add(500*time.Millisecond)))
+
+	// reserve in the future
+	assert.Equal(t, 500*time.Millisecond, l.Reserve(now.Add(500*time.Millisecond)))
+	assert.Equal(t, time.Second, l.Reserve(now.Add(500*time.Millisecond)))
+
+	assert.Panics(t, func() {
+		event.NewRateLimiter(0, 1)
+	})
+}
+
+func TestManager_SetRateLimit_reject(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var calls int
+	em.Listen("user.*", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}))
+	em.SetRateLimit("user.login", 1, 2, event.RateLimitReject)
+
+	for i := 0; i < 4; i++ {
+		_, _ = em.Publish("user.login", nil)
+	}
+	err, _ := em.Publish("user.login", nil)
+
+	var rle *event.RateLimitError
+	assert.True(t, errors.As(err, &rle))
+	assert.Equal(t, "user.login", rle.EventName)
+	assert.Equal(t, "user.login", rle.Pattern)
+	assert.Contains(t, err.Error(), "rate limited by 'user.login'")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, uint64(3), em.Stats().RateLimited)
+
+	// other event is not limited
+	err, _ = em.Publish("user.logout", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// refill
+	clock.Advance(time.Second)
+	err, _ = em.Publish("user.login", nil)
+	assert.NoError(t, err)
+
+	// remove
+	em.RemoveRateLimit("user.login")
+	for i := 0; i < 5; i++ {
+		err, _ = em.Publish("user.login", nil)
+		assert.NoError(t, err)
+	}
+}
+
+func TestManager_SetRateLimit_delay(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var calls int
+	em.Listen("order.created", event.ListenerFunc(func(e event.IEvent) error {
+		calls++
+		return nil
+	}))
+	em.SetRateLimit("order.**", 10, 1, event.RateLimitDelay)
+
+	err, _ := em.Publish("order.created", nil)
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		err, _ := em.Publish("order.created", nil)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 2, calls)
+
+	// canceled on waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		err, _ := em.PublishContext(ctx, "order.created", nil)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, 2, calls)
+}
+
+func TestManager_ListenerRateLimit(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+
+	var errs []error
+	em := event.NewManager("test", event.WithClock(clock), event.WithErrorHandler(func(e event.IEvent, err error) {
+		errs = append(errs, err)
+	}))
+
+	var limited, normal int
+	sub := em.ListenItem("e1", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			limited++
+			return nil
+		}),
+		RateLimit: event.NewRateLimiter(1, 1),
+	})
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		normal++
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		err, _ := em.Publish("e1", nil)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, limited)
+	assert.Equal(t, 3, normal)
+	assert.Equal(t, uint64(2), em.Stats().ListenerRateLimited)
+
+	var rle *event.RateLimitError
+	assert.Len(t, errs, 2)
+	assert.True(t, errors.As(errs[0], &rle))
+	assert.Equal(t, sub.ID(), rle.ListenerID)
+	assert.Contains(t, rle.Error(), "for listener")
+
+	clock.Advance(time.Second)
+	_, _ = em.Publish("e1", nil)
+	assert.Equal(t, 2, limited)
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPanicManager(policy int, handled *[]error) (*event.Manager, *[]string, *event.Subscription) {
+	em := event.NewManager("test", event.WithRecoverPolicy(policy), event.WithErrorHandler(func(e event.IEvent, err error) {
+		*handled = append(*handled, err)
+	}))
+
+	got := new([]string)
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		*got = append(*got, "l1")
+		return nil
+	}), event.High)
+	sub := em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		panic("oops")
+	}))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		*got = append(*got, "l3")
+		return nil
+	}), event.Low)
+
+	return em, got, sub
+}
+
+func TestManager_RecoverPolicy(t *testing.T) {
+	var handled []error
+
+	// default, no recover
+	em, _, _ := newPanicManager(event.RecoverNone, &handled)
+	assert.Panics(t, func() {
+		_, _ = em.Publish("e1", nil)
+	})
+
+	// stop
+	em, got, sub := newPanicManager(event.RecoverStop, &handled)
+	err, _ := em.Publish("e1", nil)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"l1"}, *got)
+
+	var pe *event.PanicError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "e1", pe.EventName)
+	assert.Equal(t, sub.ID(), pe.ListenerID)
+	assert.Equal(t, "oops", pe.Value)
+	assert.NotEmpty(t, pe.Stack)
+	assert.Contains(t, pe.Error(), "panic on handle the event 'e1': oops")
+
+	// skip
+	em, got, _ = newPanicManager(event.RecoverSkip, &handled)
+	err, _ = em.Publish("e1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l1", "l3"}, *got)
+	assert.Len(t, handled, 1)
+	assert.True(t, errors.As(handled[0], &pe))
+
+	// continue
+	em, got, _ = newPanicManager(event.RecoverContinue, &handled)
+	err, _ = em.Publish("e1", nil)
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, []string{"l1", "l3"}, *got)
+}
+
+func TestPanicError_Unwrap(t *testing.T) {
+	myErr := fmt.Errorf("my error")
+	em := event.NewManager("test", event.WithRecoverPolicy(event.RecoverStop))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		panic(myErr)
+	}))
+
+	err, _ := em.Publish("e1", nil)
+	assert.ErrorIs(t, err, myErr)
+}
+
+func TestManager_AsyncPublish_recover(t *testing.T) {
+	errCh := make(chan error, 1)
+	em := event.NewManager("test", event.WithRecoverPolicy(event.RecoverStop), event.WithErrorHandler(func(e event.IEvent, err error) {
+		errCh <- err
+	}))
+	em.Listen("e1", event.ListenerFunc(func(e event.IEvent) error {
+		panic("oops")
+	}))
+
+	assert.NoError(t, em.AsyncPublish(event.NewBasicEvent("e1", nil)))
+	assert.NoError(t, em.Close())
+
+	var pe *event.PanicError
+	assert.True(t, errors.As(<-errCh, &pe))
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTemporary = errors.New("temporary error")
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &event.RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(5))
+
+	p.Multiplier = 3
+	assert.Equal(t, 300*time.Millisecond, p.Backoff(2))
+
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		d := p.Backoff(1)
+		assert.True(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, d)
+	}
+}
+
+func TestManager_ListenItem_retry(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+
+	var mu sync.Mutex
+	var attempts []int
+	em := event.NewManager("test", event.WithClock(clock), event.WithOnRetry(func(e event.IEvent, li *event.ListenerItem, attempt int, err error) {
+		mu.Lock()
+		attempts = append(attempts, attempt)
+		mu.Unlock()
+	}))
+
+	var calls int
+	em.ListenItem("e1", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			calls++
+			if calls < 3 {
+				return errTemporary
+			}
+			return nil
+		}),
+		Retry: &event.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second},
+	})
+
+	done := make(chan error)
+	go func() {
+		err, _ := em.Publish("e1", nil)
+		done <- err
+	}()
+
+	// first retry wait 1s, second retry wait 2s
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Second)
+
+	assert.NoError(t, <-done)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Equal(t, uint64(2), em.Stats().Retries)
+	assert.Equal(t, uint64(0), em.Stats().RetryFailures)
+}
+
+func TestManager_ListenItem_retryExhausted(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var calls int
+	em.ListenItem("e1", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			calls++
+			return errTemporary
+		}),
+		Retry: &event.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Second},
+	})
+
+	done := make(chan error)
+	go func() {
+		err, _ := em.Publish("e1", nil)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	assert.ErrorIs(t, <-done, errTemporary)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, uint64(1), em.Stats().Retries)
+	assert.Equal(t, uint64(1), em.Stats().RetryFailures)
+}
+
+func TestManager_ListenItem_retryable(t *testing.T) {
+	em := event.NewManager("test", event.WithClock(event.NewFakeClock(time.Now())))
+
+	var calls int
+	em.ListenItem("e1", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			calls++
+			return errNotify
+		}),
+		Retry: &event.RetryPolicy{
+			MaxAttempts: 3,
+			Retryable: func(err error) bool {
+				return errors.Is(err, errTemporary)
+			},
+		},
+	})
+
+	err, _ := em.Publish("e1", nil)
+	assert.ErrorIs(t, err, errNotify)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, uint64(0), em.Stats().Retries)
+}
+
+func TestManager_ListenItem_retryCanceled(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithClock(clock))
+	em.ListenItem("e1", &event.ListenerItem{
+		Listener: event.ListenerFunc(func(e event.IEvent) error {
+			return errTemporary
+		}),
+		Retry: &event.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		err, _ := em.PublishContext(ctx, "e1", nil)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := event.NewFakeClock(start)
+
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() {
+		fired = append(fired, "2s")
+	})
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, "1s")
+	})
+	tm := clock.AfterFunc(3*time.Second, func() {
+		fired = append(fired, "3s")
+	})
+	ch := clock.After(time.Second)
+	assert.Equal(t, 4, clock.Waiters())
+
+	assert.True(t, tm.Stop())
+	assert.False(t, tm.Stop())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, []string{"1s", "2s"}, fired)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, start.Add(2*time.Second), <-ch)
+	assert.Equal(t, 0, clock.Waiters())
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_PublishAfter(t *testing.T) {
+	clock := event.NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var got []string
+	em.Listen("job.*", event.ListenerFunc(func(e event.IEvent) error {
+		got = append(got, e.Get("id").(string))
+		return nil
+	}))
+
+	em.PublishAfter(3*time.Second, "job.run", event.M{"id": "c"})
+	s1 := em.PublishAfter(time.Second, "job.run", event.M{"id": "a"})
+	em.PublishAt(clock.Now().Add(2*time.Second), "job.run", event.M{"id": "b"})
+	em.PublishAfter(time.Second, "job.run", event.M{"id": "a2"})
+	assert.Equal(t, "job.run", s1.Name())
+	assert.Equal(t, clock.Now().Add(time.Second), s1.Time())
+
+	// only one timer for the earliest schedule
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Empty(t, got)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, []string{"a", "a2"}, got)
+	assert.False(t, s1.Cancel())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, []string{"a", "a2", "b", "c"}, got)
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestSchedule_Cancel(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+	em := event.NewManager("test", event.WithClock(clock))
+
+	var got []string
+	em.Listen("job.run", event.ListenerFunc(func(e event.IEvent) error {
+		got = append(got, e.Get("id").(string))
+		return nil
+	}))
+
+	s1 := em.PublishAfter(time.Second, "job.run", event.M{"id": "a"})
+	s2 := em.PublishAfter(2*time.Second, "job.run", event.M{"id": "b"})
+	em.PublishAfter(3*time.Second, "job.run", event.M{"id": "c"})
+
+	assert.True(t, s1.Cancel())
+	assert.False(t, s1.Cancel())
+	assert.True(t, s2.Cancel())
+
+	clock.Advance(2 * time.Second)
+	assert.Empty(t, got)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"c"}, got)
+}
+
+func TestManager_PublishAfter_error(t *testing.T) {
+	clock := event.NewFakeClock(time.Now())
+
+	var errs []error
+	em := event.NewManager("test", event.WithClock(clock), event.WithErrorHandler(func(e event.IEvent, err error) {
+		errs = append(errs, err)
+	}))
+	em.Listen("job.run", event.ListenerFunc(func(e event.IEvent) error {
+		return errNotify
+	}))
+
+	em.PublishAfter(time.Second, "job.run", nil)
+	clock.Advance(time.Second)
+	assert.Equal(t, []error{errNotify}, errs)
+
+	// discarded on close
+	s := em.PublishAfter(time.Second, "job.run", nil)
+	assert.NoError(t, em.Close())
+	assert.False(t, s.Cancel())
+	clock.Advance(time.Second)
+	assert.Len(t, errs, 1)
+
+	assert.Panics(t, func() {
+		em.PublishAfter(time.Second, "", nil)
+	})
+}
+
+func TestManager_PublishAfter_realClock(t *testing.T) {
+	em := event.NewManager("test")
+	done := make(chan struct{})
+	em.ListenOnce("job.run", event.ListenerFunc(func(e event.IEvent) error {
+		close(done)
+		return nil
+	}))
+
+	em.PublishAfter(5*time.Millisecond, "job.run", nil)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the delayed event is not published")
+	}
+}
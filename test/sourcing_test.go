@@ -0,0 +1,261 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAccount struct {
+	event.AggregateBase
+	Balance float64 `json:"balance"`
+	Applied int     `json:"-"`
+}
+
+func (a *testAccount) Apply(e event.IEvent) {
+	a.Applied++
+	amount, _ := e.Get("amount").(float64)
+	switch e.Name() {
+	case "account.deposited":
+		a.Balance += amount
+	case "account.withdrawn":
+		a.Balance -= amount
+	}
+}
+
+func (a *testAccount) Deposit(amount float64) {
+	event.ApplyChange(a, event.NewBasicEvent("account.deposited", event.M{"amount": amount}))
+}
+
+func (a *testAccount) Withdraw(amount float64) {
+	event.ApplyChange(a, event.NewBasicEvent("account.withdrawn", event.M{"amount": amount}))
+}
+
+func newTestAccount() event.Aggregate {
+	return &testAccount{}
+}
+
+func testEventStore(t *testing.T, store event.EventStore) {
+	em := event.NewManager("test")
+	var published []string
+	em.Listen("account.*", event.ListenerFunc(func(e event.IEvent) error {
+		published = append(published, e.Name())
+		return nil
+	}))
+
+	repo := event.NewRepository(em, store, newTestAccount, event.WithSnapshotEvery(3))
+	ctx := context.Background()
+
+	a, err := repo.Load("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, a.Version())
+	assert.NoError(t, repo.Save(ctx, a)) // no changes
+
+	acc := a.(*testAccount)
+	acc.Deposit(100)
+	acc.Withdraw(30)
+	assert.Equal(t, float64(70), acc.Balance)
+	assert.Len(t, acc.Changes(), 2)
+
+	assert.NoError(t, repo.Save(ctx, acc))
+	assert.Equal(t, 2, acc.Version())
+	assert.Empty(t, acc.Changes())
+	assert.Equal(t, []string{"account.deposited", "account.withdrawn"}, published)
+
+	v, err := store.Version("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	es, err := store.Load("acc-1", 1)
+	assert.NoError(t, err)
+	assert.Len(t, es, 1)
+	assert.Equal(t, "account.withdrawn", es[0].Name())
+	assert.Equal(t, "acc-1", es[0].(event.IEnvelope).Meta()[event.MetaAggregateID])
+
+	// no snapshot yet
+	snap, err := store.LoadSnapshot("acc-1")
+	assert.NoError(t, err)
+	assert.Nil(t, snap)
+
+	// concurrent modify
+	a2, err := repo.Load("acc-1")
+	assert.NoError(t, err)
+	a2.(*testAccount).Deposit(5)
+
+	acc.Deposit(10)
+	assert.NoError(t, repo.Save(ctx, acc))
+
+	err = repo.Save(ctx, a2)
+	var vErr *event.VersionConflictError
+	assert.True(t, errors.As(err, &vErr))
+	assert.Equal(t, 2, vErr.Expected)
+	assert.Equal(t, 3, vErr.Actual)
+
+	// the snapshot is saved at version 3
+	snap, err = store.LoadSnapshot("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, snap.Version)
+
+	acc.Withdraw(20)
+	assert.NoError(t, repo.Save(ctx, acc))
+
+	// load from the snapshot, only apply the events after it
+	a3, err := repo.Load("acc-1")
+	assert.NoError(t, err)
+	acc3 := a3.(*testAccount)
+	assert.Equal(t, "acc-1", acc3.AggregateID())
+	assert.Equal(t, 4, acc3.Version())
+	assert.Equal(t, float64(60), acc3.Balance)
+	assert.Equal(t, 1, acc3.Applied)
+
+	// append with any version
+	assert.NoError(t, store.Append("acc-1", event.AnyVersion, []event.IEvent{
+		event.NewBasicEvent("account.deposited", event.M{"amount": float64(1)}),
+	}))
+	v, _ = store.Version("acc-1")
+	assert.Equal(t, 5, v)
+}
+
+func TestMemoryEventStore(t *testing.T) {
+	testEventStore(t, event.NewMemoryEventStore())
+}
+
+func TestFileEventStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := event.NewFileEventStore(dir, nil)
+	assert.NoError(t, err)
+	testEventStore(t, store)
+
+	// reopen
+	store, err = event.NewFileEventStore(dir, &event.JSONCodec{})
+	assert.NoError(t, err)
+	v, err := store.Version("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, v)
+
+	repo := event.NewRepository(nil, store, newTestAccount)
+	a, err := repo.Load("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(61), a.(*testAccount).Balance)
+}
+
+func TestNewRepository_panics(t *testing.T) {
+	assert.Panics(t, func() {
+		event.NewRepository(nil, nil, newTestAccount)
+	})
+	assert.Panics(t, func() {
+		event.NewRepository(nil, event.NewMemoryEventStore(), nil)
+	})
+}
+
+func TestRepository_Save_stampEnvelope(t *testing.T) {
+	store, err := event.NewFileEventStore(t.TempDir(), nil)
+	assert.NoError(t, err)
+
+	clock := event.NewFakeClock(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+	em := event.NewManager("bank", event.WithClock(clock))
+	repo := event.NewRepository(em, store, newTestAccount)
+
+	a, _ := repo.Load("acc-1")
+	a.(*testAccount).Deposit(100)
+	assert.NoError(t, repo.Save(context.Background(), a))
+
+	// without the manager
+	a.(*testAccount).Deposit(10)
+	assert.NoError(t, event.NewRepository(nil, store, newTestAccount).Save(context.Background(), a))
+
+	es, err := store.Load("acc-1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, es, 2)
+
+	env := es[0].(event.IEnvelope)
+	assert.Len(t, env.ID(), 26)
+	assert.Equal(t, "bank", env.Source())
+	assert.True(t, clock.Now().Equal(env.Time()))
+
+	env = es[1].(event.IEnvelope)
+	assert.Len(t, env.ID(), 26)
+	assert.False(t, env.Time().IsZero())
+}
+
+func TestFileEventStore_SaveSnapshot_concurrent(t *testing.T) {
+	dir := t.TempDir()
+	store, err := event.NewFileEventStore(dir, nil)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(v int) {
+			defer wg.Done()
+			assert.NoError(t, store.SaveSnapshot(&event.Snapshot{AggregateID: "acc-1", Version: v, State: []byte("{}")}))
+		}(i)
+	}
+	wg.Wait()
+
+	snap, err := store.LoadSnapshot("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "acc-1", snap.AggregateID)
+
+	// no temp files are left
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Empty(t, files)
+}
+
+func TestEventStore_isolated(t *testing.T) {
+	store := event.NewMemoryEventStore()
+	em := event.NewManager("test")
+	em.Listen("account.*", event.ListenerFunc(func(e event.IEvent) error {
+		e.Set("amount", float64(0))
+		return nil
+	}))
+
+	repo := event.NewRepository(em, store, newTestAccount)
+	a, _ := repo.Load("acc-1")
+	a.(*testAccount).Deposit(100)
+	assert.NoError(t, repo.Save(context.Background(), a))
+
+	// the listener changes do not rewrite the history
+	es, err := store.Load("acc-1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), es[0].Get("amount"))
+
+	// the loaded events are copies too
+	es[0].Set("amount", float64(1))
+	a, err = repo.Load("acc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), a.(*testAccount).Balance)
+}
+
+func TestFileEventStore_corrupt(t *testing.T) {
+	dir := t.TempDir()
+	store, err := event.NewFileEventStore(dir, nil)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, store.Append("acc-1", event.AnyVersion, []event.IEvent{
+			event.NewBasicEvent("account.deposited", event.M{"amount": float64(i)}),
+		}))
+	}
+
+	// flip a byte of the first record
+	path := filepath.Join(dir, "acc-1.events")
+	bs, _ := os.ReadFile(path)
+	bs[journalPayloadOffset] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, bs, 0644))
+
+	store, err = event.NewFileEventStore(dir, nil)
+	assert.NoError(t, err)
+	_, err = store.Version("acc-1")
+	assert.ErrorIs(t, err, event.ErrJournalCorrupt)
+
+	// the committed events are not truncated
+	info, _ := os.Stat(path)
+	assert.Equal(t, int64(len(bs)), info.Size())
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	em := event.NewManager("test")
+
+	var got []int
+	newListener := func(n int) event.ListenerFunc {
+		// all closures share the same code pointer
+		return func(e event.IEvent) error {
+			got = append(got, n)
+			return nil
+		}
+	}
+
+	s1 := em.Listen("e1", newListener(1))
+	s2 := em.Listen("e1", newListener(2))
+	assert.NotEqual(t, s1.ID(), s2.ID())
+	assert.Equal(t, "e1", s1.Name())
+	assert.Equal(t, 2, em.ListenersCount("e1"))
+
+	s1.Unsubscribe()
+	assert.Equal(t, 1, em.ListenersCount("e1"))
+
+	err, _ := em.Publish("e1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, got)
+
+	// repeat is ok
+	s1.Unsubscribe()
+	assert.Equal(t, 1, em.ListenersCount("e1"))
+
+	s2.Unsubscribe()
+	assert.False(t, em.HasListeners("e1"))
+}
+
+func TestSubscriptions_Unsubscribe(t *testing.T) {
+	em := event.NewManager("test")
+	ss := em.Subscribe(&testSubscriber{})
+	assert.Len(t, ss, 3)
+
+	ss.Unsubscribe()
+	assert.Empty(t, em.ListenedNames())
+}
+
+func TestManager_RemoveListener_struct(t *testing.T) {
+	em := event.NewManager("test")
+	l1 := &testListener{userData: "l1"}
+	l2 := &testListener{userData: "l2"}
+
+	em.Listen("e1", l1)
+	em.Listen("e1", l2)
+	em.Listen("e2", l1)
+
+	em.RemoveListener("e1", l1)
+	assert.Equal(t, 1, em.ListenersCount("e1"))
+	assert.True(t, em.HasListeners("e2"))
+
+	em.RemoveListener("", l1)
+	assert.False(t, em.HasListeners("e2"))
+	assert.Equal(t, 1, em.ListenersCount("e1"))
+}
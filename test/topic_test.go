@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bychannel/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	Name string
+}
+
+func TestTopic(t *testing.T) {
+	em := event.NewManager("test")
+	topic := event.NewTopic[*testUser](em, "user.created")
+	assert.Equal(t, "user.created", topic.Name())
+
+	var got []string
+	topic.Listen(func(ctx context.Context, u *testUser) error {
+		got = append(got, "typed:"+u.Name)
+		return nil
+	}, event.High)
+
+	// group and wildcard listeners receive the IEvent
+	em.Listen("user.*", event.ListenerFunc(func(e event.IEvent) error {
+		u, ok := event.PayloadOf[*testUser](e)
+		assert.True(t, ok)
+		got = append(got, "group:"+u.Name)
+		return nil
+	}))
+	em.Listen("*", event.ListenerFunc(func(e event.IEvent) error {
+		te, ok := e.(*event.TypedEvent[*testUser])
+		assert.True(t, ok)
+		got = append(got, "all:"+te.Payload().Name)
+		return nil
+	}))
+
+	err := topic.Publish(context.Background(), &testUser{Name: "inhere"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"typed:inhere", "group:inhere", "all:inhere"}, got)
+}
+
+func TestTopic_Publish_untyped(t *testing.T) {
+	em := event.NewManager("test")
+	topic := event.NewTopic[int](em, "counter")
+
+	var sum int
+	sub := topic.Listen(func(ctx context.Context, n int) error {
+		sum += n
+		return nil
+	})
+
+	// publish by name, with the payload key
+	err, _ := em.Publish("counter", event.M{event.PayloadKey: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sum)
+
+	// invalid payload type
+	err, _ = em.Publish("counter", event.M{event.PayloadKey: "3"})
+	assert.Error(t, err)
+
+	sub.Unsubscribe()
+	assert.NoError(t, topic.Publish(context.Background(), 4))
+	assert.Equal(t, 3, sum)
+}
+
+func TestTopic_Publish_error(t *testing.T) {
+	em := event.NewManager("test")
+	topic := event.NewTopic[string](em, "msg")
+	topic.Listen(func(ctx context.Context, s string) error {
+		return fmt.Errorf("bad message: %s", s)
+	})
+
+	assert.EqualError(t, topic.Publish(context.Background(), "hi"), "bad message: hi")
+	assert.Panics(t, func() {
+		event.NewTopic[string](em, "++invalid")
+	})
+}
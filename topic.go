@@ -0,0 +1,78 @@
+package event
+
+import (
+	"context"
+	"fmt"
+)
+
+// PayloadKey the data key of the typed payload in event data
+const PayloadKey = "payload"
+
+// TypedEvent the event with a typed payload. it is published by the Topic.
+type TypedEvent[T any] struct {
+	BasicEvent
+	payload T
+}
+
+// NewTypedEvent new a typed event instance
+func NewTypedEvent[T any](name string, payload T) *TypedEvent[T] {
+	e := &TypedEvent[T]{payload: payload}
+	e.SetName(name)
+	e.SetData(M{PayloadKey: payload})
+	return e
+}
+
+// Payload get the typed payload
+func (e *TypedEvent[T]) Payload() T {
+	return e.payload
+}
+
+// PayloadOf get the typed payload from an event.
+// support the TypedEvent and the event data with key PayloadKey.
+func PayloadOf[T any](e IEvent) (T, bool) {
+	if te, ok := e.(*TypedEvent[T]); ok {
+		return te.payload, true
+	}
+
+	v, ok := e.Get(PayloadKey).(T)
+	return v, ok
+}
+
+// Topic is a typed event bus of an event name. it routes through the
+// manager, so the priorities, group and wildcard listeners still apply.
+//
+// Usage:
+// 	topic := event.NewTopic[*User](em, "user.created")
+// 	topic.Listen(func(ctx context.Context, u *User) error { ... })
+// 	err := topic.Publish(ctx, &User{})
+type Topic[T any] struct {
+	em   *Manager
+	name string
+}
+
+// NewTopic create a typed topic on the manager
+func NewTopic[T any](em *Manager, name string) *Topic[T] {
+	return &Topic[T]{em: em, name: checkName(name)}
+}
+
+// Name get the topic event name
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish the payload to the topic listeners
+func (t *Topic[T]) Publish(ctx context.Context, payload T) error {
+	return t.em.publishContext(ctx, NewTypedEvent(t.name, payload))
+}
+
+// Listen register a typed listener with priority.
+func (t *Topic[T]) Listen(fn func(ctx context.Context, payload T) error, priority ...int) *Subscription {
+	return t.em.Listen(t.name, ContextListenerFunc(func(ctx context.Context, e IEvent) error {
+		payload, ok := PayloadOf[T](e)
+		if !ok {
+			var zero T
+			return fmt.Errorf("event: the event '%s' payload is not %T", e.Name(), zero)
+		}
+		return fn(ctx, payload)
+	}), priority...)
+}
@@ -0,0 +1,90 @@
+package event
+
+// topicTrie is the route trie of the listened patterns.
+// each node is a segment of the pattern, split by '.'
+type topicTrie struct {
+	children map[string]*topicTrie
+	// star node for the "*" segment
+	star *topicTrie
+	// deep node for the "**" segment
+	deep *topicTrie
+	// pattern is not empty on the node is the end of a pattern
+	pattern string
+}
+
+// insert a pattern to the trie
+func (n *topicTrie) insert(pattern string) {
+	node := n
+	rest, has := pattern, true
+	for has {
+		var seg string
+		seg, rest, has = cutSegment(rest)
+
+		switch seg {
+		case Wildcard:
+			if node.star == nil {
+				node.star = &topicTrie{}
+			}
+			node = node.star
+		case DeepWildcard:
+			if node.deep == nil {
+				node.deep = &topicTrie{}
+			}
+			node = node.deep
+		default:
+			if node.children == nil {
+				node.children = make(map[string]*topicTrie)
+			}
+
+			child, ok := node.children[seg]
+			if !ok {
+				child = &topicTrie{}
+				node.children[seg] = child
+			}
+			node = child
+		}
+	}
+
+	node.pattern = pattern
+}
+
+// match collect all patterns matched the event name. the result may contain duplicates.
+func (n *topicTrie) match(name string, out []string) []string {
+	seg, rest, has := cutSegment(name)
+
+	if child, ok := n.children[seg]; ok {
+		out = child.next(rest, has, out)
+	}
+	if n.star != nil {
+		out = n.star.next(rest, has, out)
+	}
+	if n.deep != nil {
+		out = n.deep.deepNext(rest, has, out)
+	}
+	return out
+}
+
+// next on the node has consumed one segment
+func (n *topicTrie) next(rest string, has bool, out []string) []string {
+	if !has {
+		if n.pattern != "" {
+			out = append(out, n.pattern)
+		}
+		return out
+	}
+	return n.match(rest, out)
+}
+
+// deepNext on the "**" node has consumed one segment, it can consume more segments.
+func (n *topicTrie) deepNext(rest string, has bool, out []string) []string {
+	// "**" at the end, match all remaining segments.
+	if n.pattern != "" {
+		out = append(out, n.pattern)
+	}
+
+	for has {
+		out = n.match(rest, out)
+		_, rest, has = cutSegment(rest)
+	}
+	return out
+}